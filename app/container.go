@@ -57,6 +57,7 @@ func (c *Container) Initialize() error {
 	err := tools.Initialize(c.config, c.logger)
 	if err != nil {
 		c.logger.Error("预加载错误：", err)
+		return err
 	}
 
 	return nil
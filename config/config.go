@@ -6,8 +6,10 @@ import (
 )
 
 type Config struct {
-	Bot BotConfig
-	LLM LLMConfig
+	Bot        BotConfig
+	LLM        LLMConfig
+	Download   DownloadConfig
+	FileServer FileServerConfig
 }
 
 // LLMData 存储一个模型的配置
@@ -17,11 +19,22 @@ type LLMData struct {
 	Model   string `toml:"model"`
 }
 
+// LLMQuotaConfig 每天的大模型用量上限，为0时不限制
+type LLMQuotaConfig struct {
+	// UserTokens、UserRequests 每个用户每天的token数和请求次数
+	UserTokens   int64 `toml:"userTokens"`
+	UserRequests int64 `toml:"userRequests"`
+	// GroupTokens、GroupRequests 每个群每天的token数和请求次数
+	GroupTokens   int64 `toml:"groupTokens"`
+	GroupRequests int64 `toml:"groupRequests"`
+}
+
 // LLMConfig 表示对于大模型的配置
 type LLMConfig struct {
-	Text    LLMData `toml:"Text"`
-	Choice  LLMData `toml:"Choice"`
-	Dynamic LLMData `toml:"Dynamic"`
+	Text    LLMData        `toml:"Text"`
+	Choice  LLMData        `toml:"Choice"`
+	Dynamic LLMData        `toml:"Dynamic"`
+	Quota   LLMQuotaConfig `toml:"Quota"`
 }
 
 // BotConfig 代表TOML文件中的bot部分
@@ -32,6 +45,85 @@ type BotConfig struct {
 	CachePath  string `toml:"cachePath"`
 }
 
+// DownloadConfig 表示下载课程文件的配置
+type DownloadConfig struct {
+	// ZipVolumeSize 打包下载时单个分卷的大小，单位为MB
+	ZipVolumeSize int64 `toml:"zipVolumeSize"`
+	// FileCacheSize 本地文件缓存的大小上限，单位为MB
+	FileCacheSize int64 `toml:"fileCacheSize"`
+	// GlobalTransfers、UserTransfers、GroupTransfers 分别为全局、每个用户、每个群同时传输的文件数
+	GlobalTransfers int `toml:"globalTransfers"`
+	UserTransfers   int `toml:"userTransfers"`
+	GroupTransfers  int `toml:"groupTransfers"`
+}
+
+// DefaultZipVolumeSize 未配置时的分卷大小，单位为MB
+const DefaultZipVolumeSize = 2048
+
+// GetZipVolumeSize 返回分卷大小，单位为字节
+func (c *DownloadConfig) GetZipVolumeSize() int64 {
+	if c.ZipVolumeSize <= 0 {
+		return DefaultZipVolumeSize << 20
+	}
+	return c.ZipVolumeSize << 20
+}
+
+// DefaultFileCacheSize 未配置时的文件缓存大小上限，单位为MB
+const DefaultFileCacheSize = 4096
+
+// GetFileCacheSize 返回文件缓存大小上限，单位为字节
+func (c *DownloadConfig) GetFileCacheSize() int64 {
+	if c.FileCacheSize <= 0 {
+		return DefaultFileCacheSize << 20
+	}
+	return c.FileCacheSize << 20
+}
+
+// 未配置时同时传输的文件数
+const (
+	DefaultGlobalTransfers = 16
+	DefaultUserTransfers   = 4
+	DefaultGroupTransfers  = 8
+)
+
+func defaultInt(value, def int) int {
+	if value <= 0 {
+		return def
+	}
+	return value
+}
+
+func (c *DownloadConfig) GetGlobalTransfers() int {
+	return defaultInt(c.GlobalTransfers, DefaultGlobalTransfers)
+}
+
+func (c *DownloadConfig) GetUserTransfers() int {
+	return defaultInt(c.UserTransfers, DefaultUserTransfers)
+}
+
+func (c *DownloadConfig) GetGroupTransfers() int {
+	return defaultInt(c.GroupTransfers, DefaultGroupTransfers)
+}
+
+// FileServerConfig 表示内置文件服务器的配置，上传失败时提供下载链接
+type FileServerConfig struct {
+	Enabled bool   `toml:"enabled"`
+	Listen  string `toml:"listen"`
+	// BaseURL 用户访问文件服务器的地址，如 https://example.com
+	BaseURL string `toml:"baseURL"`
+	// TTL 下载链接的有效期，单位为分钟
+	TTL int `toml:"ttl"`
+	// Secret 签名下载链接的密钥，为空时每次启动随机生成
+	Secret string `toml:"secret"`
+}
+
+// DefaultFileServerTTL 未配置时下载链接的有效期，单位为分钟
+const DefaultFileServerTTL = 60
+
+func (c *FileServerConfig) GetTTL() int {
+	return defaultInt(c.TTL, DefaultFileServerTTL)
+}
+
 // GlobalConfig 默认全局配置
 var GlobalConfig *Config
 
@@ -24,6 +24,11 @@ func NewLogicManager(client *client.QQClient) *LogicManager {
 	}
 }
 
+// GetClient 获取QQ客户端
+func (lm *LogicManager) GetClient() *client.QQClient {
+	return lm.client
+}
+
 // GetRouter 获取路由器
 func (lm *LogicManager) GetRouter() *Router {
 	return lm.router
@@ -41,6 +41,7 @@ func RecoveryMiddleware() Middleware {
 			defer func() {
 				if r := recover(); r != nil {
 					logrus.Errorf("消息处理器发生panic: %v", r)
+					ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: 内部错误")})
 				}
 			}()
 			return next(ctx)
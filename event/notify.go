@@ -0,0 +1,25 @@
+package event
+
+import (
+	"errors"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+)
+
+// Notify 主动向目标推送消息，群聊中会@目标用户
+func (lm *LogicManager) Notify(target tools.NotifyTarget, elements []message.IMessageElement) error {
+	if target.GroupUin != 0 {
+		if target.Uin != 0 {
+			elements = append([]message.IMessageElement{message.NewAt(target.Uin), message.NewText(" \n")}, elements...)
+		}
+		_, err := lm.client.SendGroupMessage(target.GroupUin, elements)
+		return err
+	}
+
+	if target.Uin == 0 {
+		return errors.New("推送目标为空")
+	}
+	_, err := lm.client.SendPrivateMessage(target.Uin, elements)
+	return err
+}
@@ -0,0 +1,109 @@
+package event
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// PendingHandler 处理用户对上一次提问的回复
+type PendingHandler func(ctx *MessageContext, text string)
+
+// PendingMatcher 判断回复是否是对上一次提问的有效回应，返回false时继续正常匹配指令，不能有副作用
+type PendingMatcher func(text string) bool
+
+type pendingKey struct {
+	GroupUin uint32
+	Uin      uint32
+}
+
+type pendingItem struct {
+	match   PendingMatcher
+	handler PendingHandler
+	expire  time.Time
+}
+
+// PendingStore 保存等待用户回复的选择，按照群和发送者区分
+type PendingStore struct {
+	m sync.Map
+}
+
+// Pending 全局的等待回复存储
+var Pending PendingStore
+
+func pendingKeyOf(ctx *MessageContext) pendingKey {
+	target := ctx.GetNotifyTarget()
+	return pendingKey{GroupUin: target.GroupUin, Uin: target.Uin}
+}
+
+// Set 等待当前会话中发送者的下一条回复，超过ttl后失效，会覆盖之前的等待
+func (p *PendingStore) Set(ctx *MessageContext, ttl time.Duration, match PendingMatcher, handler PendingHandler) {
+	p.m.Store(pendingKeyOf(ctx), &pendingItem{match: match, handler: handler, expire: time.Now().Add(ttl)})
+}
+
+func (p *PendingStore) Delete(ctx *MessageContext) {
+	p.m.Delete(pendingKeyOf(ctx))
+}
+
+// Take 取出消息对应的等待，返回处理这条回复的函数，以 / 开头的指令和无效的回复不会被拦截，返回nil时等待保留
+func (p *PendingStore) Take(ctx *MessageContext) func(ctx *MessageContext) {
+	key := pendingKeyOf(ctx)
+	data, ok := p.m.Load(key)
+	if !ok {
+		return nil
+	}
+	item, ok := data.(*pendingItem)
+	if !ok || time.Now().After(item.expire) {
+		p.m.CompareAndDelete(key, data)
+		return nil
+	}
+
+	text := strings.TrimSpace(ctx.GetText())
+	if text == "" || strings.HasPrefix(text, "/") || !item.match(text) {
+		return nil
+	}
+	// 处理期间不再接受同一个等待，处理器中可以设置新的等待
+	if !p.m.CompareAndDelete(key, data) {
+		return nil
+	}
+	return func(ctx *MessageContext) {
+		item.handler(ctx, text)
+	}
+}
+
+// SetSelection 等待用户回复 "1,3,5-8" 格式的序号，超出范围的数字不作为选择，继续正常匹配指令
+func (p *PendingStore) SetSelection(ctx *MessageContext, ttl time.Duration, max int, f func(ctx *MessageContext, indices []int)) {
+	p.Set(ctx, ttl, selectionMatcher(max), func(reply *MessageContext, text string) {
+		indices, _ := utils.ParseSelection(text, max)
+		f(reply, indices)
+	})
+}
+
+// SetSingleSelection 等待用户回复一个序号，回复多个有效序号时提示用户并继续等待
+func (p *PendingStore) SetSingleSelection(ctx *MessageContext, ttl time.Duration, max int, f func(ctx *MessageContext, index int)) {
+	item := &pendingItem{match: selectionMatcher(max), expire: time.Now().Add(ttl)}
+	item.handler = func(reply *MessageContext, text string) {
+		indices, _ := utils.ParseSelection(text, max)
+		if len(indices) != 1 {
+			reply.SendMessage([]message.IMessageElement{message.NewText("选择无效: 只能选择一个序号")})
+			p.m.Store(pendingKeyOf(reply), item)
+			return
+		}
+		f(reply, indices[0])
+	}
+	p.m.Store(pendingKeyOf(ctx), item)
+}
+
+// selectionMatcher 只接受所有序号都在 1-max 之间的回复
+func selectionMatcher(max int) PendingMatcher {
+	return func(text string) bool {
+		if !utils.IsSelection(text) {
+			return false
+		}
+		_, err := utils.ParseSelection(text, max)
+		return err == nil
+	}
+}
@@ -0,0 +1,65 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	message2 "github.com/vintcessun/XMU-CM-Bot/message"
+)
+
+func newTestContext(text string) *MessageContext {
+	return NewMessageContext(nil, message2.NewMessage(&message.PrivateMessage{
+		Sender:   &message.Sender{Uin: 1},
+		Elements: []message.IMessageElement{message.NewText(text)},
+	}))
+}
+
+func TestPendingSelection(t *testing.T) {
+	var store PendingStore
+	var selected []int
+	store.SetSelection(newTestContext("/search 高数"), time.Minute, 3, func(ctx *MessageContext, indices []int) {
+		selected = indices
+	})
+
+	tests := []struct {
+		text string
+		// taken 回复是否被等待拦截
+		taken bool
+	}{
+		{text: "666"},
+		{text: "2024"},
+		{text: "0"},
+		{text: "1-5"},
+		{text: "谢谢"},
+		{text: "/download 高数"},
+		{text: "1,3", taken: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			take := store.Take(newTestContext(tt.text))
+			if (take != nil) != tt.taken {
+				t.Fatalf("回复 %q 被拦截 = %v, 期望 %v", tt.text, take != nil, tt.taken)
+			}
+			if take != nil {
+				take(newTestContext(tt.text))
+			}
+		})
+	}
+
+	if len(selected) != 2 || selected[0] != 1 || selected[1] != 3 {
+		t.Fatalf("选择 = %v, 期望 [1 3]", selected)
+	}
+	// 选择之后等待结束
+	if store.Take(newTestContext("2")) != nil {
+		t.Fatal("选择之后不应继续等待")
+	}
+}
+
+func TestPendingSelectionExpired(t *testing.T) {
+	var store PendingStore
+	store.SetSelection(newTestContext("/search 高数"), -time.Second, 3, func(ctx *MessageContext, indices []int) {})
+	if store.Take(newTestContext("1")) != nil {
+		t.Fatal("过期的等待不应拦截回复")
+	}
+}
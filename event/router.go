@@ -3,8 +3,10 @@ package event
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/LagrangeDev/LagrangeGo/client"
 	"github.com/LagrangeDev/LagrangeGo/message"
@@ -12,6 +14,7 @@ import (
 	"github.com/sirupsen/logrus"
 	message2 "github.com/vintcessun/XMU-CM-Bot/message"
 	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
 )
 
 // MessageContext 消息上下文
@@ -29,8 +32,9 @@ func NewMessageContext(client *client.QQClient, msg message2.Message) *MessageCo
 		Client:   client,
 		Message:  msg,
 		Metadata: make(map[string]interface{}),
-		ctx:      context.Background(),
 	}
+	// 记录发送者，用于统计大模型用量和限制额度
+	ret.ctx = tools.WithLLMCaller(context.Background(), ret.GetNotifyTarget())
 	ret.InitMessageText()
 	return &ret
 }
@@ -70,8 +74,20 @@ func (mc *MessageContext) GetSender() (*message.Sender, bool) {
 
 func (mc *MessageContext) AssertGroupAndRejectExpired() (string, bool) {
 	msg := mc.AssertGroupMessage()
+	return mc.rejectExpired(msg.Sender.Uin)
+}
+
+// RejectExpired 获取发送者的登录数据，适用于群聊、私聊和临时会话
+func (mc *MessageContext) RejectExpired() (string, bool) {
+	sender, ok := mc.GetSender()
+	if !ok || sender == nil {
+		return "", false
+	}
+	return mc.rejectExpired(sender.Uin)
+}
 
-	session, ok := tools.Login.Get(msg.Sender.Uin)
+func (mc *MessageContext) rejectExpired(uin uint32) (string, bool) {
+	session, ok := tools.Login.Get(uin)
 	if !ok {
 		mc.SendMessage([]message.IMessageElement{message.NewText("请先使用/login命令登录课程平台")})
 		return session, false
@@ -88,6 +104,16 @@ func (mc *MessageContext) SendMessage(elements []message.IMessageElement) (inter
 	return mc.Message.SendMessage(mc.Client, elements)
 }
 
+// RecallMessage 撤回 SendMessage 发送的消息，目前只支持群消息
+func (mc *MessageContext) RecallMessage(sent interface{}) error {
+	switch msg := sent.(type) {
+	case *message.GroupMessage:
+		return mc.Client.RecallGroupMessage(msg.GroupUin, msg.ID)
+	default:
+		return errors.New("不支持撤回该类型的消息")
+	}
+}
+
 func (mc *MessageContext) SendFileLocal(localFilePath, filename string, folderId ...string) error {
 	return mc.Message.SendFileLocal(mc.Client, localFilePath, filename, folderId...)
 }
@@ -96,6 +122,16 @@ func (mc *MessageContext) SendFileURL(url, filename string, client *resty.Client
 	return mc.Message.SendFileURL(mc.Client, url, filename, client, folderId...)
 }
 
+// SendCourseFile 通过本地文件缓存发送课程文件
+func (mc *MessageContext) SendCourseFile(file *tools.FormatFileInside, client *resty.Client, folderId ...string) error {
+	path, release, err := tools.FileCache.Fetch(file, client)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return mc.SendFileLocal(path, file.Name, folderId...)
+}
+
 func (mc *MessageContext) GetMessage() interface{} {
 	return mc.Message.GetMessage()
 }
@@ -180,6 +216,31 @@ func (mc *MessageContext) AssertTempMessage() *message.TempMessage {
 	panic("Assert Message is Group Message Failed")
 }
 
+// IsTempMessage 是否为临时会话，临时会话中的文件会上传到来源群的群文件
+func (mc *MessageContext) IsTempMessage() bool {
+	_, ok := mc.GetTempMessage()
+	return ok
+}
+
+// FileDeliveryNote 返回文件发送方式的提示，只有临时会话需要提示
+func (mc *MessageContext) FileDeliveryNote() string {
+	if mc.IsTempMessage() {
+		return "临时会话无法直接发送文件，文件已上传到群文件的「" + message2.TempFileFolderName + "」文件夹，添加机器人为好友后可以私聊接收文件"
+	}
+	return ""
+}
+
+// RejectNotPrivateMessage 个人数据只能通过好友私聊发送，临时会话的文件会上传到群文件
+func (mc *MessageContext) RejectNotPrivateMessage() bool {
+	_, ok := mc.GetPrivateMessage()
+	if !ok {
+		mc.SendMessage([]message.IMessageElement{
+			message.NewText("本指令包含个人信息，请添加机器人为好友后私聊使用"),
+		})
+	}
+	return ok
+}
+
 func (mc *MessageContext) RejectNotGroupMessage() bool {
 	_, ok := mc.GetGroupMessage()
 	if !ok {
@@ -195,6 +256,29 @@ func (mc *MessageContext) GetText() string {
 	return mc.text
 }
 
+// GetArgs 获取指令之后的参数
+func (mc *MessageContext) GetArgs() []string {
+	fields := strings.Fields(mc.text)
+	for i, field := range fields {
+		if strings.HasPrefix(field, "/") {
+			return fields[i+1:]
+		}
+	}
+	return nil
+}
+
+// GetNotifyTarget 获取当前消息对应的推送目标
+func (mc *MessageContext) GetNotifyTarget() tools.NotifyTarget {
+	if groupMsg, ok := mc.GetGroupMessage(); ok {
+		return tools.NotifyTarget{Uin: groupMsg.Sender.Uin, GroupUin: groupMsg.GroupUin}
+	}
+	sender, _ := mc.GetSender()
+	if sender == nil {
+		return tools.NotifyTarget{}
+	}
+	return tools.NotifyTarget{Uin: sender.Uin}
+}
+
 func (mc *MessageContext) CreateGroupFileFolder(name string) (string, error) {
 	grpMsg := mc.AssertGroupMessage()
 	err := mc.Client.CreateGroupFolder(grpMsg.GroupUin, "/", name)
@@ -214,6 +298,56 @@ func (mc *MessageContext) CreateGroupFileFolder(name string) (string, error) {
 	return "", errors.New("未找到文件夹")
 }
 
+// FindOrCreateGroupFileFolder 查找或创建群文件中的固定文件夹
+func (mc *MessageContext) FindOrCreateGroupFileFolder(name string) (string, error) {
+	grpMsg := mc.AssertGroupMessage()
+	return message2.FindOrCreateGroupFolder(mc.Client, grpMsg.GroupUin, name)
+}
+
+// chosenCourseKey 用户在候选课程中选择的课程保存在元数据中的键
+const chosenCourseKey = "chosen_course"
+
+// courseSelectionTimeout 等待用户选择课程的时间
+var courseSelectionTimeout = 5 * time.Minute
+
+// GetCommandCourse 根据指令选择课程，用户已经在候选课程中选择过时直接使用选择的课程
+func (mc *MessageContext) GetCommandCourse(command string, client *resty.Client) (*tools.FormatCourseInside, error) {
+	if course, ok := mc.Get(chosenCourseKey); ok {
+		if course, ok := course.(*tools.FormatCourseInside); ok {
+			return course, nil
+		}
+	}
+	return tools.GetCommandCourse(mc.ctx, command, client)
+}
+
+// AskCourse 无法确定课程时列出候选课程，用户回复序号后使用选择的课程重新执行 retry，
+// retry 需要复用元数据中已经请求过大模型的结果，返回false时表示err不是课程不明确的错误
+func (mc *MessageContext) AskCourse(err error, retry func(ctx *MessageContext)) bool {
+	var ambiguous *tools.AmbiguousCourseError
+	if !errors.As(err, &ambiguous) || len(ambiguous.Candidates) == 0 {
+		return false
+	}
+
+	candidates := ambiguous.Candidates
+	Pending.SetSingleSelection(mc, courseSelectionTimeout, len(candidates), func(reply *MessageContext, index int) {
+		course := candidates[index-1]
+		utils.Info("选择课程 ", course.Name)
+		mc.Set(chosenCourseKey, course)
+		retry(mc)
+	})
+
+	data := []string{fmt.Sprintf("无法确定是哪一门课，请在 %d 分钟内回复一个序号选择:", int(courseSelectionTimeout.Minutes()))}
+	for i, course := range candidates {
+		if course.Semester == "" {
+			data = append(data, fmt.Sprintf("%d. %s", i+1, course.Name))
+		} else {
+			data = append(data, fmt.Sprintf("%d. %s (%s)", i+1, course.Name, course.Semester))
+		}
+	}
+	mc.SendMessage([]message.IMessageElement{message.NewText(strings.Join(data, "\n"))})
+	return true
+}
+
 // extractTextFromElements 从消息元素中提取文本
 func extractTextFromElements(elements []message.IMessageElement) string {
 	var textParts []string
@@ -326,6 +460,21 @@ func (router *Router) Handle(ctx *MessageContext) {
 	copy(middlewares, router.middlewares)
 	router.mu.RUnlock()
 
+	// 优先处理等待中的选择，只有有效的回复才会被拦截，选择的回调同样经过全局中间件
+	if take := Pending.Take(ctx); take != nil {
+		pending := HandlerFunc(func(ctx *MessageContext) error {
+			take(ctx)
+			return nil
+		})
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			pending = middlewares[i](pending)
+		}
+		if err := pending(ctx); err != nil {
+			router.errorHandler(err, ctx)
+		}
+		return
+	}
+
 	// 为每个路由执行处理
 	for _, route := range routes {
 		// 创建完整的中间件链（全局中间件 + 路由中间件）
@@ -0,0 +1,219 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// maxAgentSteps 一次请求中最多请求大模型的次数
+var maxAgentSteps = 6
+
+// agentTool 注册给大模型调用的工具
+type agentTool struct {
+	tool tools.LLMTool
+	// status 调用时发送给用户的进度提示
+	status string
+	run    func(call *agentCall, arguments string) (string, error)
+}
+
+// newTool 根据参数类型T生成工具的参数schema，调用前按照schema校验参数
+func newTool[T any](name, description, status string, run func(call *agentCall, args *T) (string, error)) *agentTool {
+	return &agentTool{
+		tool:   tools.LLMTool{Name: name, Description: description, Parameters: tools.SchemaOf[T]()},
+		status: status,
+		run: func(call *agentCall, arguments string) (string, error) {
+			args, err := tools.ParseToolArguments[T](arguments)
+			if err != nil {
+				return "", fmt.Errorf("参数不正确: %w", err)
+			}
+			return run(call, args)
+		},
+	}
+}
+
+// agentCall 一次自然语言请求的上下文
+type agentCall struct {
+	ctx     *event.MessageContext
+	session string
+}
+
+// getSession 需要访问课程平台时才获取登录数据，未登录时把原因交给大模型转告用户
+func (c *agentCall) getSession() (string, error) {
+	if c.session != "" {
+		return c.session, nil
+	}
+	sender, ok := c.ctx.GetSender()
+	if !ok || sender == nil {
+		return "", errors.New("无法获取用户信息")
+	}
+	session, ok := tools.Login.Get(sender.Uin)
+	if !ok {
+		return "", errors.New("用户尚未登录，请提示用户先使用 /login 登录课程平台")
+	}
+	if !tools.CheckSession.CheckSession(session) {
+		return "", errors.New("用户的登录数据已过期，请提示用户使用 /login 重新登录")
+	}
+	c.session = session
+	return session, nil
+}
+
+func (c *agentCall) sendText(text string) {
+	c.ctx.SendMessage([]message.IMessageElement{message.NewText(text)})
+}
+
+func (c *agentCall) runTool(toolCall tools.LLMToolCall) string {
+	var tool *agentTool
+	for _, t := range agentTools {
+		if t.tool.Name == toolCall.Name {
+			tool = t
+			break
+		}
+	}
+	if tool == nil {
+		return "没有名为 " + toolCall.Name + " 的工具"
+	}
+
+	utils.Info("调用工具 ", toolCall.Name, " ", toolCall.Arguments)
+	c.sendText(tool.status)
+	result, err := tool.run(c, toolCall.Arguments)
+	if err != nil {
+		utils.Warn("工具调用失败 ", toolCall.Name, " ", err)
+		return "调用失败: " + err.Error()
+	}
+	return result
+}
+
+// elementsText 提取消息中的文本，作为工具的结果交给大模型
+func elementsText(elements []message.IMessageElement) string {
+	var data []string
+	for _, element := range elements {
+		if text, ok := element.(*message.TextElement); ok {
+			data = append(data, text.Content)
+		}
+	}
+	return strings.Join(data, "")
+}
+
+func getAgentPrompt() string {
+	return strings.Join([]string{`# 身份
+你是厦门大学课程平台的QQ机器人助手，帮助用户查询课程、作业、成绩、公告和下载课程文件。
+# 要求
+1.  需要课程平台的数据时调用工具获取，不要编造课程、作业、成绩等信息。
+2.  下载文件和搜索文件的工具会直接把文件或结果发送给用户，不需要再重复列出。
+3.  工具返回登录相关的错误时，提示用户先使用 /login 登录。
+4.  QQ不支持Markdown，请使用简洁的中文纯文本回复。
+# 当前时间
+`, time.Now().Format("2006-01-02 15:04 Monday")}, "")
+}
+
+func agentFunc(text string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	call := &agentCall{ctx: ctx}
+	var definitions []tools.LLMTool
+	for _, tool := range agentTools {
+		definitions = append(definitions, tool.tool)
+	}
+
+	messages := []tools.LLMMessage{
+		{Role: tools.LLMRoleSystem, Content: getAgentPrompt()},
+		{Role: tools.LLMRoleUser, Content: text},
+	}
+	for range maxAgentSteps {
+		reply, err := tools.Llm.Text.ChatWithTools(ctx.GetContext(), messages, definitions)
+		var quotaErr *tools.LLMQuotaError
+		if errors.As(err, &quotaErr) {
+			return nil, err
+		}
+		if err != nil {
+			utils.Warn("LLM请求失败 ", err)
+			return nil, errors.New("大模型请求失败")
+		}
+
+		content := strings.TrimSpace(tools.RemoveThinkTags(reply.Content))
+		if len(reply.ToolCalls) == 0 {
+			if content == "" {
+				content = "已完成"
+			}
+			return []message.IMessageElement{message.NewText(content)}, nil
+		}
+
+		// 调用工具前的说明作为中间状态发送
+		if content != "" {
+			call.sendText(content)
+		}
+		messages = append(messages, tools.LLMMessage{Role: tools.LLMRoleAssistant, Content: reply.Content, ToolCalls: reply.ToolCalls})
+		for _, toolCall := range reply.ToolCalls {
+			messages = append(messages, tools.LLMMessage{Role: tools.LLMRoleTool, ToolCallId: toolCall.Id, Content: call.runTool(toolCall)})
+		}
+	}
+
+	return nil, fmt.Errorf("超过 %d 步仍未完成，请把需求说得更具体一些", maxAgentSteps)
+}
+
+// intentKeywords 私聊和临时会话中需要包含这些词才进入自然语言模式，避免闲聊消息也请求大模型
+var intentKeywords = []string{
+	"课", "文件", "资料", "下载", "搜索", "找", "作业", "考试", "测验", "截止", "ddl", "成绩", "分数", "公告", "通知",
+}
+
+// hasIntent 判断私聊消息是否是对课程功能的请求，单独的序号是过期选择的回复，不处理
+func hasIntent(text string) bool {
+	if utils.IsSelection(text) {
+		return false
+	}
+	text = strings.ToLower(text)
+	for _, keyword := range intentKeywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match 群聊中@机器人，或者私聊、临时会话中不以指令开头且与课程功能相关的消息进入自然语言模式
+func Match(ctx *event.MessageContext) bool {
+	text := strings.TrimSpace(ctx.GetText())
+	if text == "" {
+		return false
+	}
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(field, "/") {
+			return false
+		}
+	}
+
+	sender, ok := ctx.GetSender()
+	if !ok || sender == nil || sender.Uin == ctx.Client.Uin {
+		return false
+	}
+
+	groupMsg, ok := ctx.GetGroupMessage()
+	if !ok {
+		return hasIntent(text)
+	}
+	for _, element := range groupMsg.Elements {
+		if at, ok := element.(*message.AtElement); ok && at.TargetUin == ctx.Client.Uin {
+			return true
+		}
+	}
+	return false
+}
+
+func Agent(ctx *event.MessageContext) {
+	utils.Info("处理自然语言请求")
+	defer utils.Info("处理结束自然语言请求")
+
+	result, err := agentFunc(strings.TrimSpace(ctx.GetText()), ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
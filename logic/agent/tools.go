@@ -0,0 +1,191 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/vintcessun/XMU-CM-Bot/logic/download"
+	"github.com/vintcessun/XMU-CM-Bot/logic/search"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var maxNoticeResult = 5
+
+type coursesArgs struct {
+	All bool `json:"all,omitempty" description:"是否包含以往学期的课程，默认只列出本学期"`
+}
+
+type scoreArgs struct {
+	Keyword string `json:"keyword,omitempty" description:"课程名关键词，为空时查询本学期全部课程"`
+}
+
+type noticeArgs struct {
+	Course string `json:"course,omitempty" description:"课程名或对课程的描述，为空时查询本学期所有课程"`
+}
+
+type searchArgs struct {
+	Keywords []string `json:"keywords" description:"文件名或活动标题中的关键词"`
+}
+
+type downloadArgs struct {
+	Request string `json:"request" description:"用户对课程和文件的描述，如 高等数学 最新的课件"`
+	Zip     bool   `json:"zip,omitempty" description:"是否打包成压缩包发送，文件较多时使用"`
+}
+
+var agentTools = []*agentTool{
+	newTool("list_courses", "列出用户的课程", "正在查询课程列表", listCourses),
+	newTool("get_deadlines", "查询本学期所有未截止的作业、考试和测验", "正在查询未截止的任务", getDeadlines),
+	newTool("get_scores", "查询课程成绩，只能在私聊中使用", "正在查询成绩", getScores),
+	newTool("get_notices", "查询最新的课程公告", "正在查询课程公告", getNotices),
+	newTool("search_files", "在所有课程中按关键词搜索文件，并把结果列表发送给用户选择", "正在搜索文件", searchFiles),
+	newTool("download_files", "下载某一门课程的文件并发送给用户，可以按描述筛选文件", "正在下载课程文件", downloadFiles),
+}
+
+func listCourses(call *agentCall, args *coursesArgs) (string, error) {
+	session, err := call.getSession()
+	if err != nil {
+		return "", err
+	}
+	client := utils.GetSessionClient(session)
+	courseData, err := tools.GetCourseData(client)
+	if err != nil {
+		utils.Warn("获取课程信息失败 ", err)
+		return "", errors.New("获取课程信息失败")
+	}
+	if !args.All {
+		courseData = tools.GetCurrentCourseData(courseData)
+	}
+	if len(*courseData) == 0 {
+		return "没有找到课程", nil
+	}
+
+	var data []string
+	for _, course := range *courseData {
+		data = append(data, fmt.Sprintf("%s (id: %d) 学期: %s 开课单位: %s", course.Name, course.Id, course.Semester, course.Department))
+	}
+	return strings.Join(data, "\n"), nil
+}
+
+func getDeadlines(call *agentCall, _ *struct{}) (string, error) {
+	session, err := call.getSession()
+	if err != nil {
+		return "", err
+	}
+	tasks, err := tools.GetUpcomingTasks(utils.GetSessionClient(session))
+	if err != nil {
+		utils.Warn("获取截止任务失败 ", err)
+		return "", errors.New("获取截止任务失败")
+	}
+	if len(tasks) == 0 {
+		return "当前学期没有未截止的作业、考试或测验", nil
+	}
+	return tools.CourseTaskListString(tasks), nil
+}
+
+func getScores(call *agentCall, args *scoreArgs) (string, error) {
+	// 成绩属于敏感信息，不在群聊中发送
+	if _, isGroup := call.ctx.GetGroupMessage(); isGroup {
+		return "", errors.New("成绩不能在群聊中查询，请提示用户私聊机器人")
+	}
+	session, err := call.getSession()
+	if err != nil {
+		return "", err
+	}
+	scores, err := tools.GetCourseScores(utils.GetSessionClient(session))
+	if err != nil {
+		utils.Warn("获取成绩失败 ", err)
+		return "", errors.New("获取成绩失败")
+	}
+
+	semester := tools.CurrentSemester()
+	if args.Keyword != "" {
+		semester = ""
+	}
+	scores = tools.FilterCourseScores(scores, semester, args.Keyword)
+	if len(scores) == 0 {
+		return "没有找到对应课程的成绩", nil
+	}
+	return tools.CourseScoreTableString(scores), nil
+}
+
+func getNotices(call *agentCall, args *noticeArgs) (string, error) {
+	session, err := call.getSession()
+	if err != nil {
+		return "", err
+	}
+	client := utils.GetSessionClient(session)
+
+	var courses tools.FormatCourseData
+	if args.Course == "" {
+		courseData, err := tools.GetCourseData(client)
+		if err != nil {
+			utils.Warn("获取课程信息失败 ", err)
+			return "", errors.New("获取课程信息失败")
+		}
+		courses = *tools.GetCurrentCourseData(courseData)
+	} else {
+		course, err := tools.GetCommandCourse(call.ctx.GetContext(), args.Course, client)
+		if err != nil {
+			return "", err
+		}
+		courses = tools.FormatCourseData{course}
+	}
+
+	var announcements []*tools.Announcement
+	for _, course := range courses {
+		data, err := tools.GetCourseAnnouncements(course, client)
+		if err != nil {
+			utils.Warn("获取课程公告失败 ", course.Name, " ", err)
+			continue
+		}
+		announcements = append(announcements, data...)
+	}
+	if len(announcements) == 0 {
+		return "没有找到课程公告", nil
+	}
+
+	tools.SortAnnouncements(announcements)
+	if len(announcements) > maxNoticeResult {
+		announcements = announcements[:maxNoticeResult]
+	}
+	var data []string
+	for _, announcement := range announcements {
+		data = append(data, announcement.ToString())
+	}
+	return strings.Join(data, "\n\n"), nil
+}
+
+func searchFiles(call *agentCall, args *searchArgs) (string, error) {
+	if len(args.Keywords) == 0 {
+		return "", errors.New("缺少搜索关键词")
+	}
+	session, err := call.getSession()
+	if err != nil {
+		return "", err
+	}
+	result, err := search.SearchFiles(session, args.Keywords, call.ctx)
+	if err != nil {
+		return "", err
+	}
+	call.ctx.SendMessage(result)
+	return "已把搜索结果发送给用户: " + elementsText(result), nil
+}
+
+func downloadFiles(call *agentCall, args *downloadArgs) (string, error) {
+	session, err := call.getSession()
+	if err != nil {
+		return "", err
+	}
+	command := args.Request
+	if args.Zip {
+		command += " --zip"
+	}
+	result, err := download.DownloadFiles(session, command, call.ctx)
+	if err != nil {
+		return "", err
+	}
+	call.ctx.SendMessage(result)
+	return "已把下载结果发送给用户: " + elementsText(result), nil
+}
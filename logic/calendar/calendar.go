@@ -0,0 +1,72 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var calendarFileName = "XMU课程表.ics"
+
+func calendarFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	calendar, err := tools.BuildSchedule(client)
+	if err != nil {
+		utils.Warn("生成课程表失败 ", err)
+		return nil, errors.New("生成课程表失败")
+	}
+	if len(calendar.Events) == 0 {
+		return nil, errors.New("当前学期没有可以导出的课程或截止时间")
+	}
+
+	tempFile, err := os.CreateTemp("", "calendar-*.ics")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.WriteString(calendar.ToICS())
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.SendFileLocal(tempFile.Name(), calendarFileName)
+	if err != nil {
+		utils.Warn("发送课程表失败 ", err)
+		return nil, errors.New("发送课程表失败")
+	}
+
+	return []message.IMessageElement{message.NewText(fmt.Sprintf("已生成课程表，共 %d 个事件，可以导入到任意日历应用", len(calendar.Events)))}, nil
+}
+
+func Calendar(ctx *event.MessageContext) {
+	utils.Info("处理calendar指令")
+	defer utils.Info("处理结束calendar指令")
+
+	// 课程表属于个人信息，不能上传到群文件
+	if !ctx.RejectNotPrivateMessage() {
+		return
+	}
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := calendarFunc(session, ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
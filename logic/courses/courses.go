@@ -0,0 +1,119 @@
+package courses
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var unknownSemester = "未知学期"
+
+// filterCourses 根据参数筛选课程，支持 current、all 和 <学年> <学期>
+func filterCourses(courseData *tools.FormatCourseData, args []string) (*tools.FormatCourseData, error) {
+	if len(args) == 0 {
+		return tools.GetCurrentCourseData(courseData), nil
+	}
+
+	switch args[0] {
+	case "current", "当前", "本学期":
+		return tools.GetCurrentCourseData(courseData), nil
+	case "all", "全部":
+		return courseData, nil
+	}
+
+	academicYear, ok := tools.ParseAcademicYear(args[0])
+	if !ok {
+		return nil, errors.New("参数格式错误，可用参数: current/all/<学年> <学期>，如 2024-2025 1")
+	}
+	term := ""
+	if len(args) > 1 {
+		term, ok = tools.ParseSemesterTerm(args[1])
+		if !ok {
+			return nil, errors.New("学期格式错误，可用: 1/2/3 或 秋/春/夏")
+		}
+	}
+	return tools.FilterCourseDataBySemester(courseData, academicYear, term), nil
+}
+
+func courseString(course *tools.FormatCourseInside, client *resty.Client) string {
+	instructors := "未知"
+	info, err := tools.GetCourseInfo(client, course.Id)
+	if err != nil {
+		utils.Warn("获取课程详情失败 ", course.Id, " ", err)
+	} else if len(info.Instructors) > 0 {
+		var names []string
+		for _, instructor := range info.Instructors {
+			names = append(names, instructor.Name)
+		}
+		instructors = strings.Join(names, "、")
+	}
+	return fmt.Sprintf("%s (id: %d) 开课单位: %s 教师: %s", course.Name, course.Id, course.Department, instructors)
+}
+
+func coursesFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	courseData, err := tools.GetCourseData(client)
+	if err != nil {
+		utils.Warn("获取课程信息失败 ", err)
+		return nil, errors.New("获取课程信息失败")
+	}
+
+	filtered, err := filterCourses(courseData, ctx.GetArgs())
+	if err != nil {
+		return nil, err
+	}
+	if len(*filtered) == 0 {
+		return []message.IMessageElement{message.NewText("没有找到对应学期的课程")}, nil
+	}
+
+	groups := make(map[string][]*tools.FormatCourseInside)
+	var semesters []string
+	for _, course := range *filtered {
+		semester := course.Semester
+		if semester == "" {
+			semester = unknownSemester
+		}
+		if _, ok := groups[semester]; !ok {
+			semesters = append(semesters, semester)
+		}
+		groups[semester] = append(groups[semester], course)
+	}
+	sort.SliceStable(semesters, func(i, j int) bool {
+		return tools.SemesterOrder(semesters[i]) > tools.SemesterOrder(semesters[j])
+	})
+
+	var data []string
+	for _, semester := range semesters {
+		data = append(data, semester)
+		for i, course := range groups[semester] {
+			data = append(data, fmt.Sprintf("%d. %s", i+1, courseString(course, client)))
+		}
+	}
+	return []message.IMessageElement{message.NewText(strings.Join(data, "\n"))}, nil
+}
+
+func Courses(ctx *event.MessageContext) {
+	utils.Info("处理courses指令")
+	defer utils.Info("处理结束courses指令")
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := coursesFunc(session, ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
@@ -0,0 +1,73 @@
+package deadline
+
+import (
+	"errors"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+func setReminder(uin uint32, enabled bool) ([]message.IMessageElement, error) {
+	err := tools.SetDeadlineSetting(uin, &tools.DeadlineSetting{Disabled: !enabled})
+	if err != nil {
+		utils.Warn("保存截止提醒设置失败 ", err)
+		return nil, errors.New("保存设置失败")
+	}
+
+	if enabled {
+		return []message.IMessageElement{message.NewText("已开启截止提醒，将在截止前24小时和1小时私聊提醒")}, nil
+	}
+	return []message.IMessageElement{message.NewText("已关闭截止提醒")}, nil
+}
+
+func listTasks(session string) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	tasks, err := tools.GetUpcomingTasks(client)
+	if err != nil {
+		utils.Warn("获取截止任务失败 ", err)
+		return nil, errors.New("获取截止任务失败")
+	}
+
+	if len(tasks) == 0 {
+		return []message.IMessageElement{message.NewText("当前学期没有未截止的作业、考试或测验")}, nil
+	}
+	return []message.IMessageElement{message.NewText("未截止的任务如下:\n" + tools.CourseTaskListString(tasks))}, nil
+}
+
+func deadlineFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	args := ctx.GetArgs()
+	if len(args) == 0 {
+		return listTasks(session)
+	}
+
+	uin := ctx.GetNotifyTarget().Uin
+	switch args[0] {
+	case "on", "开启":
+		return setReminder(uin, true)
+	case "off", "关闭":
+		return setReminder(uin, false)
+	default:
+		return nil, errors.New("未知参数，可用参数: on/off")
+	}
+}
+
+func Deadline(ctx *event.MessageContext) {
+	utils.Info("处理deadline指令")
+	defer utils.Info("处理结束deadline指令")
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := deadlineFunc(session, ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
@@ -0,0 +1,160 @@
+package deadline
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var reminderCheckDelay = 1 * time.Minute
+var reminderRefreshDelay = 1 * time.Hour
+
+// reminderOffsets 提醒时间点，需要从大到小排列
+var reminderOffsets = []struct {
+	Name   string
+	Before time.Duration
+}{
+	{Name: "24h", Before: 24 * time.Hour},
+	{Name: "1h", Before: 1 * time.Hour},
+}
+
+type reminderScheduler struct {
+	// refreshLoop 刷新任务需要逐个请求课程平台，单独运行避免推迟到期提醒的发送
+	refreshLoop utils.Loop
+	taskLoop    utils.Loop
+}
+
+var scheduler reminderScheduler
+
+// scheduleTask 为任务创建或更新提醒，只会补发最近一个已经错过的提醒
+func scheduleTask(uin uint32, task *tools.CourseTask, now time.Time) {
+	for i, offset := range reminderOffsets {
+		remindAt := task.EndTime.Add(-offset.Before)
+		existing, ok := tools.GetDeadlineReminder(uin, task, offset.Name)
+		if ok && existing.Task.EndTime.Equal(task.EndTime) {
+			continue
+		}
+
+		passed := now.After(remindAt)
+		nextPassed := i+1 < len(reminderOffsets) && now.After(task.EndTime.Add(-reminderOffsets[i+1].Before))
+		reminder := tools.DeadlineReminder{
+			Uin:      uin,
+			Task:     *task,
+			RemindAt: remindAt,
+			Before:   offset.Name,
+			Sent:     passed && nextPassed,
+		}
+		err := tools.PutDeadlineReminder(&reminder)
+		if err != nil {
+			utils.Warn("保存截止提醒失败 ", err)
+		}
+	}
+}
+
+func (s *reminderScheduler) refresh() {
+	now := time.Now()
+	tools.Login.Range(func(uin uint32, session string) bool {
+		if tools.GetDeadlineSetting(uin).Disabled {
+			return true
+		}
+		if !tools.CheckSession.CheckSession(session) {
+			return true
+		}
+
+		client := utils.GetSessionClient(session)
+		tasks, err := tools.GetUpcomingTasks(client)
+		if err != nil {
+			utils.Warn("获取截止任务失败 ", uin, " ", err)
+			return true
+		}
+		for _, task := range tasks {
+			scheduleTask(uin, task, now)
+		}
+		return true
+	})
+}
+
+func (s *reminderScheduler) sendDue() {
+	reminders, err := tools.GetAllDeadlineReminders()
+	if err != nil {
+		utils.Warn("读取截止提醒失败 ", err)
+		return
+	}
+
+	// 同一个任务有多个到期的提醒时只发送最近的一个，其余的一起标记为已发送
+	now := time.Now()
+	due := make(map[string][]*tools.DeadlineReminder)
+	var keys []string
+	for _, reminder := range reminders {
+		_, loggedIn := tools.Login.Get(reminder.Uin)
+		if !loggedIn || now.After(reminder.Task.EndTime.Add(24*time.Hour)) {
+			if err := tools.DeleteDeadlineReminder(reminder); err != nil {
+				utils.Warn("删除过期截止提醒失败 ", err)
+			}
+			continue
+		}
+		if reminder.Sent || now.Before(reminder.RemindAt) || now.After(reminder.Task.EndTime) {
+			continue
+		}
+		if tools.GetDeadlineSetting(reminder.Uin).Disabled {
+			continue
+		}
+
+		key := fmt.Sprintf("%d-%s", reminder.Uin, reminder.Task.Key())
+		if _, ok := due[key]; !ok {
+			keys = append(keys, key)
+		}
+		due[key] = append(due[key], reminder)
+	}
+
+	for _, key := range keys {
+		taskReminders := due[key]
+		latest := taskReminders[0]
+		for _, reminder := range taskReminders[1:] {
+			if reminder.RemindAt.After(latest.RemindAt) {
+				latest = reminder
+			}
+		}
+
+		left := latest.Task.EndTime.Sub(now).Round(time.Minute)
+		err := event.Manager.Notify(tools.NotifyTarget{Uin: latest.Uin}, []message.IMessageElement{
+			message.NewText(fmt.Sprintf("截止提醒: 还剩 %s\n%s", left, latest.Task.ToString())),
+		})
+		if err != nil {
+			utils.Warn("发送截止提醒失败 ", err)
+			continue
+		}
+
+		for _, reminder := range taskReminders {
+			reminder.Sent = true
+			if err := tools.PutDeadlineReminder(reminder); err != nil {
+				utils.Warn("保存截止提醒失败 ", err)
+			}
+		}
+	}
+}
+
+// StartScheduler 启动截止提醒任务
+func StartScheduler() {
+	if !scheduler.taskLoop.Start(reminderCheckDelay, scheduler.sendDue) {
+		utils.Warn("截止提醒任务正在运行")
+		return
+	}
+	scheduler.refreshLoop.Start(reminderRefreshDelay, scheduler.refresh)
+
+	utils.Info("截止提醒任务已启动")
+}
+
+// StopScheduler 停止截止提醒任务
+func StopScheduler() {
+	if !scheduler.taskLoop.Stop() {
+		return
+	}
+	scheduler.refreshLoop.Stop()
+
+	utils.Info("截止提醒任务已停止")
+}
@@ -4,7 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/LagrangeDev/LagrangeGo/message"
@@ -49,27 +48,71 @@ func intToBase62(num int64) string {
 	return result
 }
 
+// parseFlag 判断指令中是否包含开关参数，返回去掉该参数后的指令
+func parseFlag(command, flag string) (bool, string) {
+	found := false
+	var rest []string
+	for _, field := range strings.Fields(command) {
+		if field == flag {
+			found = true
+			continue
+		}
+		rest = append(rest, field)
+	}
+	return found, strings.Join(rest, " ")
+}
+
 type fileSendErrResponse struct {
 	File *tools.FormatFileInside
 	Err  error
 }
 
-func downloadFunc(session string, command string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
-	client := utils.GetSessionClient(session)
+// fileFilterKey 大模型提取的筛选条件保存在元数据中的键
+const fileFilterKey = "file_filter"
 
-	courseData, err := tools.GetCourseData(client)
+// commandFileFilter 没有筛选选项但指令中有筛选描述时请求大模型提取筛选条件，结果保存在元数据中，重新执行时直接使用
+func commandFileFilter(filter *tools.FileFilter, command string, ctx *event.MessageContext) *tools.FileFilter {
+	if !filter.IsEmpty() || !tools.HasFileFilterHint(command) {
+		return filter
+	}
+	if cached, ok := ctx.Get(fileFilterKey); ok {
+		if cached, ok := cached.(*tools.FileFilter); ok {
+			return cached
+		}
+	}
+
+	llmFilter, err := tools.GetLLMFileFilter(ctx.GetContext(), command)
 	if err != nil {
-		utils.Warn("获取课程信息失败 ", err)
-		return nil, errors.New("获取课程信息失败")
+		utils.Warn("提取筛选条件失败 ", err)
+		llmFilter = filter
 	}
+	ctx.Set(fileFilterKey, llmFilter)
+	return llmFilter
+}
 
-	rencentCourseData, err := tools.GetRecentCourseData(client)
+// DownloadFiles 按照与 /download 相同的参数下载课程文件，command 中包含课程描述和选项
+func DownloadFiles(session string, command string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+
+	zipMode, command := parseFlag(command, "--zip")
+	syncMode, command := parseFlag(command, "--sync")
+	listMode, command := parseFlag(command, "--list")
+	if zipMode && syncMode {
+		return nil, errors.New("--zip 和 --sync 不能同时使用")
+	}
+	if _, isGroup := ctx.GetGroupMessage(); syncMode && !isGroup {
+		return nil, errors.New("--sync 只能在群聊中使用")
+	}
+
+	filter, command, err := tools.ParseFileFilterFlags(command)
 	if err != nil {
-		utils.Warn("获取最近课程信息失败 ", err)
-		return nil, errors.New("获取最近课程失败")
+		return nil, err
 	}
 
-	course, err := tools.GetLLMChooseCourse(courseData, rencentCourseData, command, client)
+	// 在选择课程之前提取筛选条件，课程不明确时用户选择课程后不再请求大模型
+	filter = commandFileFilter(filter, command, ctx)
+
+	course, err := ctx.GetCommandCourse(command, client)
 	if err != nil {
 		return nil, err
 	}
@@ -77,38 +120,89 @@ func downloadFunc(session string, command string, ctx *event.MessageContext) ([]
 	files, err := tools.GetCourseActivities(course.Id, client)
 	if err != nil {
 		utils.Warn("获取文件失败 ", err)
-		return nil, errors.New("获取文件失败")
+		return nil, &tools.RequestError{Message: "获取文件失败", Err: err}
+	}
+
+	if !filter.IsEmpty() {
+		filtered := filter.Apply(*files)
+		if len(filtered) == 0 {
+			return nil, fmt.Errorf("没有符合筛选条件的文件 (%s)", filter.ToString())
+		}
+		files = &filtered
+	}
+
+	if listMode {
+		return listFunc(course, *files, zipMode, syncMode, client, ctx), nil
 	}
+	return deliverFiles(course, *files, zipMode, syncMode, client, ctx)
+}
 
-	folderName := strings.Join([]string{course.Name, "-", intToBase62(time.Now().UnixMilli())}, "")
+// deliverFiles 按照指定的方式将文件上传到群文件
+func deliverFiles(course *tools.FormatCourseInside, files tools.FormatFileData, zipMode, syncMode bool, client *resty.Client, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	if zipMode {
+		return zipFunc(course, files, client, ctx)
+	}
 
-	folder, err := ctx.CreateGroupFileFolder(folderName)
-	if err != nil {
-		utils.Warn("创建文件夹失败 ", err)
+	if syncMode {
+		return SyncFiles(course, files, client, ctx)
 	}
 
+	// 私聊和临时会话直接发送文件，不创建文件夹
+	var folder string
+	if _, isGroup := ctx.GetGroupMessage(); isGroup {
+		folderName := strings.Join([]string{course.Name, "-", intToBase62(time.Now().UnixMilli())}, "")
+
+		var err error
+		folder, err = ctx.CreateGroupFileFolder(folderName)
+		if err != nil {
+			utils.Warn("创建文件夹失败 ", err)
+		}
+	}
+
+	summary, errData := uploadFiles(files, folder, client, ctx, nil)
+	if note := ctx.FileDeliveryNote(); note != "" {
+		summary += "\n" + note
+	}
+	if len(errData) == 0 {
+		return []message.IMessageElement{message.NewText("下载完成，" + summary)}, nil
+	} else {
+		return []message.IMessageElement{message.NewText("下载完成，" + summary + "\n获取文件失败如下: \n"), message.NewText(strings.Join(errData, "\n"))}, nil
+	}
+}
+
+// uploadFiles 并发上传文件到群文件夹，返回统计信息和失败的文件，每个文件上传成功后调用 uploaded
+func uploadFiles(files tools.FormatFileData, folder string, client *resty.Client, ctx *event.MessageContext, uploaded func(file *tools.FormatFileInside)) (string, []string) {
 	errChan := make(chan fileSendErrResponse)
-	var wg sync.WaitGroup
-	wg.Add(len(*files))
+	request := tools.Transfer.NewRequest(ctx.GetNotifyTarget())
+	progress := newDownloadProgress(ctx, files)
+	progress.Start()
 
-	for _, file := range *files {
-		go func() {
-			defer wg.Done()
+	for _, file := range files {
+		request.Submit(func() {
 			var err error
 			for range sendFileRetryTime {
-				err = downloadAndUploadFile(file, folder, client, ctx)
+				err = downloadAndUploadFile(file, folder, client, ctx, progress)
 				if err != nil {
 					utils.Warn("发送失败，重试 ", err)
 					continue
 				}
+				progress.Finish(file, nil)
+				if uploaded != nil {
+					uploaded(file)
+				}
 				return
 			}
+			progress.Finish(file, err)
 			errChan <- fileSendErrResponse{File: file, Err: err}
-		}()
+		})
+	}
+
+	if ahead := request.Ahead(); ahead > 0 {
+		ctx.SendMessage([]message.IMessageElement{message.NewText(fmt.Sprintf("排队中, 前面还有 %d 个文件", ahead))})
 	}
 
 	go func() {
-		wg.Wait()
+		request.Wait()
 		close(errChan)
 	}()
 
@@ -116,11 +210,11 @@ func downloadFunc(session string, command string, ctx *event.MessageContext) ([]
 
 	for errResp := range errChan {
 		var errType string
-		url, err := tools.GetURLById(errResp.File, client)
+		url, err := tools.FallbackURL(errResp.File, client)
 		if err != nil {
 			errType = "严重错误,可能是系统原因"
 		} else {
-			errType = fmt.Sprintf("可恢复错误,可能是被腾讯拦截,原文件链接为: %s", url)
+			errType = fmt.Sprintf("可恢复错误,可能是被腾讯拦截,下载链接为: %s", url)
 		}
 		perErr := fmt.Sprintf("失败文件: %s 失败ID: %d 失败原因: %s 失败类型: %s", errResp.File.Name, errResp.File.Id, errResp.Err.Error(), errType)
 
@@ -128,25 +222,18 @@ func downloadFunc(session string, command string, ctx *event.MessageContext) ([]
 
 	}
 
-	if len(errData) == 0 {
-		return []message.IMessageElement{message.NewText("下载完成")}, nil
-	} else {
-		return []message.IMessageElement{message.NewText("获取文件失败如下: \n"), message.NewText(strings.Join(errData, "\n"))}, nil
-	}
+	progress.Stop()
+	return progress.Summary(), errData
 }
 
-func downloadAndUploadFile(file *tools.FormatFileInside, folder string, client *resty.Client, ctx *event.MessageContext) error {
-	url, err := tools.GetURLById(file, client)
-	if err != nil {
-		return err
-	}
-
-	err = ctx.SendFileURL(url, file.Name, client, folder)
+func downloadAndUploadFile(file *tools.FormatFileInside, folder string, client *resty.Client, ctx *event.MessageContext, progress *downloadProgress) error {
+	progress.Begin(file)
+	path, release, err := tools.FileCache.FetchWithProgress(file, client, progress.Reader(file))
 	if err != nil {
 		return err
 	}
-
-	return nil
+	defer release()
+	return ctx.SendFileLocal(path, file.Name, folder)
 }
 
 func Download(ctx *event.MessageContext) {
@@ -155,17 +242,21 @@ func Download(ctx *event.MessageContext) {
 
 	command := ctx.GetText()
 
-	session, ok := ctx.AssertGroupAndRejectExpired()
+	session, ok := ctx.RejectExpired()
 	if !ok {
 		return
 	}
 
-	result, err := downloadFunc(session, command, ctx)
+	result, err := DownloadFiles(session, command, ctx)
+	// 只有网络请求失败时重试，没有匹配的文件、筛选条件错误和登录过期重试也是同样的结果
 	for range sendFileRetryTime {
-		if err == nil {
+		if !tools.IsTransportError(err) {
 			break
 		}
-		result, err = downloadFunc(session, command, ctx)
+		result, err = DownloadFiles(session, command, ctx)
+	}
+	if ctx.AskCourse(err, Download) {
+		return
 	}
 	if err != nil {
 		utils.Error("下载失败: ", err)
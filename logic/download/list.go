@@ -0,0 +1,42 @@
+package download
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var selectionTimeout = 5 * time.Minute
+
+// listFunc 列出课程文件，等待用户回复序号后再下载选中的文件
+func listFunc(course *tools.FormatCourseInside, files tools.FormatFileData, zipMode, syncMode bool, client *resty.Client, ctx *event.MessageContext) []message.IMessageElement {
+	data := []string{fmt.Sprintf("%s 共 %d 个文件，请在 %d 分钟内回复序号选择文件，如 1,3,5-8 或 全部:", course.Name, len(files), int(selectionTimeout.Minutes()))}
+	for i, file := range files {
+		data = append(data, fmt.Sprintf("%d. [%s] %s (%s)", i+1, file.Activity, file.FileName, utils.FormatSize(file.Size)))
+	}
+
+	event.Pending.SetSelection(ctx, selectionTimeout, len(files), func(reply *event.MessageContext, indices []int) {
+		utils.Info("处理download选择")
+		defer utils.Info("处理结束download选择")
+
+		var selected tools.FormatFileData
+		for _, index := range indices {
+			selected = append(selected, files[index-1])
+		}
+		result, err := deliverFiles(course, selected, zipMode, syncMode, client, reply)
+		if err != nil {
+			utils.Error("下载失败: ", err)
+			reply.SendMessage([]message.IMessageElement{message.NewText("下载失败: " + err.Error())})
+			return
+		}
+		reply.SendMessage(result)
+	})
+
+	return []message.IMessageElement{message.NewText(strings.Join(data, "\n"))}
+}
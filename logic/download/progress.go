@@ -0,0 +1,166 @@
+package download
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var progressInterval = 15 * time.Second
+
+// downloadProgress 记录下载进度，并定时发送进度消息
+// 群聊中新的进度消息会撤回上一条，私聊和临时会话无法撤回，只发送一条进度消息
+type downloadProgress struct {
+	mu         sync.Mutex
+	ctx        *event.MessageContext
+	recallable bool
+	total      int
+	done       int
+	failed     int
+	bytes      int64
+	totalBytes int64
+	// current 正在传输的文件已经下载的字节数
+	current map[string]int64
+	start   time.Time
+	changed bool
+	last    interface{}
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+func newDownloadProgress(ctx *event.MessageContext, files tools.FormatFileData) *downloadProgress {
+	var totalBytes int64
+	for _, file := range files {
+		totalBytes += file.Size
+	}
+	_, isGroup := ctx.GetGroupMessage()
+	return &downloadProgress{
+		ctx:        ctx,
+		recallable: isGroup,
+		total:      len(files),
+		totalBytes: totalBytes,
+		current:    make(map[string]int64),
+		start:      time.Now(),
+		stop:       make(chan struct{}),
+	}
+}
+
+// Start 开始定时发送进度消息
+func (p *downloadProgress) Start() {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.report()
+			}
+		}
+	}()
+}
+
+// Stop 停止发送进度消息并撤回最后一条进度消息
+func (p *downloadProgress) Stop() {
+	close(p.stop)
+	p.wg.Wait()
+	if p.recallable && p.last != nil {
+		if err := p.ctx.RecallMessage(p.last); err != nil {
+			utils.Warn("撤回进度消息失败 ", err)
+		}
+	}
+}
+
+func (p *downloadProgress) Begin(file *tools.FormatFileInside) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.current[file.Name] = 0
+	p.changed = true
+}
+
+// Reader 返回报告文件下载字节数的回调，重试时 Begin 会重新计数
+func (p *downloadProgress) Reader(file *tools.FormatFileInside) func(n int64) {
+	return func(n int64) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if _, ok := p.current[file.Name]; ok {
+			p.current[file.Name] += n
+			p.changed = true
+		}
+	}
+}
+
+func (p *downloadProgress) Finish(file *tools.FormatFileInside, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.current, file.Name)
+	if err != nil {
+		p.failed++
+	} else {
+		p.done++
+		p.bytes += file.Size
+	}
+	p.changed = true
+}
+
+func (p *downloadProgress) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	bytes := p.bytes
+	for _, received := range p.current {
+		bytes += received
+	}
+	text := fmt.Sprintf("下载进度: %d/%d 个文件，%s/%s", p.done+p.failed, p.total, utils.FormatSize(bytes), utils.FormatSize(p.totalBytes))
+	if p.failed > 0 {
+		text += fmt.Sprintf("，失败 %d 个", p.failed)
+	}
+	if len(p.current) > 0 {
+		var current []string
+		for name := range p.current {
+			current = append(current, name)
+		}
+		text += "\n正在传输: " + strings.Join(current, ", ")
+	}
+	return text
+}
+
+func (p *downloadProgress) report() {
+	p.mu.Lock()
+	changed := p.changed
+	p.changed = false
+	sentBefore := p.last != nil
+	p.mu.Unlock()
+	if !changed || (!p.recallable && sentBefore) {
+		return
+	}
+
+	sent, err := p.ctx.SendMessage([]message.IMessageElement{message.NewText(p.String())})
+	if err != nil {
+		utils.Warn("发送进度消息失败 ", err)
+		return
+	}
+	if p.last != nil {
+		if err := p.ctx.RecallMessage(p.last); err != nil {
+			utils.Warn("撤回进度消息失败 ", err)
+		}
+	}
+	p.mu.Lock()
+	p.last = sent
+	p.mu.Unlock()
+}
+
+// Summary 返回完成后的统计信息
+func (p *downloadProgress) Summary() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return fmt.Sprintf("共 %d 个文件 %s，用时 %s", p.done, utils.FormatSize(p.bytes), time.Since(p.start).Round(time.Second))
+}
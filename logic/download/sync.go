@@ -0,0 +1,73 @@
+package download
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// SyncFiles 将文件上传到群文件中课程对应的固定文件夹，只上传缺少或变化的文件，/pull 和 /download --sync 共用
+func SyncFiles(course *tools.FormatCourseInside, files tools.FormatFileData, client *resty.Client, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	msg := ctx.AssertGroupMessage()
+
+	folder, err := ctx.FindOrCreateGroupFileFolder(course.Name)
+	if err != nil {
+		utils.Warn("获取课程文件夹失败 ", err)
+		return nil, errors.New("获取课程文件夹失败")
+	}
+
+	groupFiles, _, err := ctx.Client.ListGroupFilesByFolder(msg.GroupUin, folder)
+	if err != nil {
+		utils.Warn("获取群文件失败 ", err)
+		return nil, errors.New("获取群文件失败")
+	}
+	var remote []*tools.RemoteFile
+	for _, file := range groupFiles {
+		remote = append(remote, &tools.RemoteFile{Id: file.FileID, Name: file.FileName, Size: int64(file.FileSize)})
+	}
+
+	plan := tools.PlanFolderSync(remote, files)
+	if plan.UploadCount() == 0 {
+		return []message.IMessageElement{message.NewText(fmt.Sprintf("%s 已是最新，共 %d 个文件", course.Name, len(plan.Skipped)))}, nil
+	}
+
+	// 变化的文件在新文件上传成功后才删除旧文件，上传期间需要额外的文件数量
+	fs, err := ctx.Client.GetGroupFileSystemInfo(msg.GroupUin)
+	if err != nil {
+		return nil, err
+	}
+	if fs.LimitCount != 0 && uint64(fs.FileCount)+uint64(plan.UploadCount()) > uint64(fs.LimitCount) {
+		return nil, fmt.Errorf("群文件数量不足，需要 %d 个，剩余 %d 个", plan.UploadCount(), fs.LimitCount-fs.FileCount)
+	}
+	if fs.TotalSpace != 0 && fs.UsedSpace+uint64(plan.UploadSize()) > fs.TotalSpace {
+		return nil, fmt.Errorf("群文件空间不足，需要 %s，剩余 %s", utils.FormatSize(plan.UploadSize()), utils.FormatSize(int64(fs.TotalSpace-fs.UsedSpace)))
+	}
+
+	uploads := plan.Missing
+	replaced := make(map[*tools.FormatFileInside]*tools.RemoteFile)
+	for _, change := range plan.Changed {
+		uploads = append(uploads, change.File)
+		replaced[change.File] = change.Remote
+	}
+
+	summary, errData := uploadFiles(uploads, folder, client, ctx, func(file *tools.FormatFileInside) {
+		old, ok := replaced[file]
+		if !ok {
+			return
+		}
+		if err := ctx.Client.DeleteGroupFile(msg.GroupUin, old.Id); err != nil {
+			utils.Warn("删除旧文件失败 ", err)
+		}
+	})
+	text := fmt.Sprintf("%s 同步完成: 新增 %d 个，更新 %d 个，跳过 %d 个，%s", course.Name, len(plan.Missing), len(plan.Changed), len(plan.Skipped), summary)
+	if len(errData) == 0 {
+		return []message.IMessageElement{message.NewText(text)}, nil
+	}
+	return []message.IMessageElement{message.NewText(text + "\n获取文件失败如下: \n"), message.NewText(strings.Join(errData, "\n"))}, nil
+}
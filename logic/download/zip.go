@@ -0,0 +1,237 @@
+package download
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/config"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// volumeWriter 按照固定大小将写入的内容切分为 name.001、name.002 等分卷
+type volumeWriter struct {
+	dir        string
+	name       string
+	volumeSize int64
+	current    *os.File
+	written    int64
+	volumes    []string
+}
+
+func newVolumeWriter(dir, name string, volumeSize int64) *volumeWriter {
+	return &volumeWriter{dir: dir, name: name, volumeSize: volumeSize}
+}
+
+func (w *volumeWriter) nextVolume() error {
+	if w.current != nil {
+		if err := w.current.Close(); err != nil {
+			return err
+		}
+	}
+	path := filepath.Join(w.dir, fmt.Sprintf("%s.%03d", w.name, len(w.volumes)+1))
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w.current = file
+	w.written = 0
+	w.volumes = append(w.volumes, path)
+	return nil
+}
+
+func (w *volumeWriter) Write(p []byte) (int, error) {
+	total := 0
+	for len(p) > 0 {
+		if w.current == nil || w.written >= w.volumeSize {
+			if err := w.nextVolume(); err != nil {
+				return total, err
+			}
+		}
+		chunk := p
+		if remain := w.volumeSize - w.written; int64(len(chunk)) > remain {
+			chunk = chunk[:remain]
+		}
+		n, err := w.current.Write(chunk)
+		total += n
+		w.written += int64(n)
+		if err != nil {
+			return total, err
+		}
+		p = p[n:]
+	}
+	return total, nil
+}
+
+func (w *volumeWriter) Close() error {
+	if w.current == nil {
+		return nil
+	}
+	return w.current.Close()
+}
+
+// Files 返回所有分卷的路径和文件名，只有一个分卷时直接命名为 name
+func (w *volumeWriter) Files() ([][2]string, error) {
+	if len(w.volumes) == 1 {
+		path := filepath.Join(w.dir, w.name)
+		if err := os.Rename(w.volumes[0], path); err != nil {
+			return nil, err
+		}
+		return [][2]string{{path, w.name}}, nil
+	}
+	var ret [][2]string
+	for _, path := range w.volumes {
+		ret = append(ret, [2]string{path, filepath.Base(path)})
+	}
+	return ret, nil
+}
+
+var zipNameReplacer = strings.NewReplacer("/", "_", "\\", "_")
+
+// zipEntryName 返回按照活动划分文件夹的压缩包内路径，重名时添加序号
+func zipEntryName(file *tools.FormatFileInside, used map[string]bool) string {
+	activity := zipNameReplacer.Replace(file.Activity)
+	if activity == "" {
+		activity = "未命名活动"
+	}
+	filename := zipNameReplacer.Replace(file.FileName)
+	name := activity + "/" + filename
+	ext := filepath.Ext(filename)
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("%s/%s (%d)%s", activity, strings.TrimSuffix(filename, ext), i, ext)
+	}
+	used[name] = true
+	return name
+}
+
+// writeZipEntry 写入已经下载到本地的文件，写入失败时压缩包中会留下不完整的条目
+func writeZipEntry(writer *zip.Writer, file *tools.FormatFileInside, name string, path string) error {
+	body, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	header := zip.FileHeader{Name: name, Method: zip.Deflate}
+	if !file.UpdatedAt.IsZero() {
+		header.Modified = file.UpdatedAt
+	}
+	entry, err := writer.CreateHeader(&header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, body)
+	return err
+}
+
+// zipFunc 将所有文件打包为一个压缩包上传，超过分卷大小时切分为多个分卷
+func zipFunc(course *tools.FormatCourseInside, files tools.FormatFileData, client *resty.Client, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	if len(files) == 0 {
+		return nil, errors.New("没有需要打包的文件")
+	}
+
+	dir, err := os.MkdirTemp("", "zip-file-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	zipName := strings.Join([]string{zipNameReplacer.Replace(course.Name), "-", intToBase62(time.Now().UnixMilli()), ".zip"}, "")
+	volumes := newVolumeWriter(dir, zipName, config.GlobalConfig.Download.GetZipVolumeSize())
+	writer := zip.NewWriter(volumes)
+
+	var errData []string
+	used := make(map[string]bool)
+	request := tools.Transfer.NewRequest(ctx.GetNotifyTarget())
+	progress := newDownloadProgress(ctx, files)
+	progress.Start()
+	defer progress.Stop()
+	for i, file := range files {
+		var fetchErr, writeErr error
+		task := request.Submit(func() {
+			progress.Begin(file)
+			// 先完整下载再写入压缩包，下载失败时不会留下不完整的条目
+			path, release, err := tools.FileCache.FetchWithProgress(file, client, progress.Reader(file))
+			if err != nil {
+				fetchErr = err
+				progress.Finish(file, err)
+				return
+			}
+			defer release()
+			writeErr = writeZipEntry(writer, file, zipEntryName(file, used), path)
+			progress.Finish(file, writeErr)
+		})
+		if ahead := request.Ahead(); i == 0 && ahead > 0 {
+			ctx.SendMessage([]message.IMessageElement{message.NewText(fmt.Sprintf("排队中, 前面还有 %d 个文件", ahead))})
+		}
+		task.Wait()
+		if writeErr != nil {
+			writer.Close()
+			volumes.Close()
+			utils.Warn("写入压缩包失败 ", writeErr)
+			return nil, fmt.Errorf("写入压缩包失败: %v", writeErr)
+		}
+		if fetchErr != nil {
+			utils.Warn("打包文件失败 ", fetchErr)
+			errData = append(errData, fmt.Sprintf("失败文件: %s 失败ID: %d 失败原因: %s", file.Name, file.Id, fetchErr.Error()))
+		}
+	}
+	if err := writer.Close(); err != nil {
+		volumes.Close()
+		return nil, err
+	}
+	if err := volumes.Close(); err != nil {
+		return nil, err
+	}
+	if len(errData) == len(files) {
+		return nil, fmt.Errorf("所有文件打包失败: \n%s", strings.Join(errData, "\n"))
+	}
+
+	uploads, err := volumes.Files()
+	if err != nil {
+		return nil, err
+	}
+	// 群聊中上传到课程文件夹，获取失败时上传到根目录
+	folder := "/"
+	if _, isGroup := ctx.GetGroupMessage(); isGroup {
+		if courseFolder, err := ctx.FindOrCreateGroupFileFolder(course.Name); err != nil {
+			utils.Warn("获取课程文件夹失败 ", err)
+		} else {
+			folder = courseFolder
+		}
+	}
+	for _, upload := range uploads {
+		var err error
+		for range sendFileRetryTime {
+			err = ctx.SendFileLocal(upload[0], upload[1], folder)
+			if err == nil {
+				break
+			}
+			utils.Warn("发送失败，重试 ", err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("上传压缩包 %s 失败: %v", upload[1], err)
+		}
+	}
+
+	text := "打包完成，" + progress.Summary()
+	if note := ctx.FileDeliveryNote(); note != "" {
+		text += "\n" + note
+	}
+	if len(uploads) > 1 {
+		text += fmt.Sprintf("，分为 %d 个分卷，请下载全部分卷后使用 7-Zip 等工具打开 .001 文件解压", len(uploads))
+	}
+	if len(errData) > 0 {
+		text += "\n获取文件失败如下: \n" + strings.Join(errData, "\n")
+	}
+	return []message.IMessageElement{message.NewText(text)}, nil
+}
@@ -2,14 +2,37 @@ package logic
 
 import (
 	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/logic/agent"
+	"github.com/vintcessun/XMU-CM-Bot/logic/calendar"
+	"github.com/vintcessun/XMU-CM-Bot/logic/courses"
+	"github.com/vintcessun/XMU-CM-Bot/logic/deadline"
 	"github.com/vintcessun/XMU-CM-Bot/logic/download"
 	"github.com/vintcessun/XMU-CM-Bot/logic/help"
 	"github.com/vintcessun/XMU-CM-Bot/logic/login"
 	"github.com/vintcessun/XMU-CM-Bot/logic/logout"
+	"github.com/vintcessun/XMU-CM-Bot/logic/notice"
+	"github.com/vintcessun/XMU-CM-Bot/logic/pull"
+	"github.com/vintcessun/XMU-CM-Bot/logic/rollcall"
+	"github.com/vintcessun/XMU-CM-Bot/logic/score"
+	"github.com/vintcessun/XMU-CM-Bot/logic/search"
+	"github.com/vintcessun/XMU-CM-Bot/logic/subscribe"
+	"github.com/vintcessun/XMU-CM-Bot/logic/usage"
 	"github.com/vintcessun/XMU-CM-Bot/utils"
 )
 
+// loggerAddHandler 注册在群聊、私聊和临时会话中都可以使用的指令
 func loggerAddHandler(command []string, function func(*event.MessageContext)) {
+	for _, cmd := range command {
+		event.Manager.HandleCommand("/", cmd, func(ctx *event.MessageContext) error {
+			utils.Info("指令内容 ", ctx.GetText())
+			function(ctx)
+			return nil
+		})
+	}
+}
+
+// loggerAddGroupHandler 注册只能在群聊中使用的指令
+func loggerAddGroupHandler(command []string, function func(*event.MessageContext)) {
 	for _, cmd := range command {
 		event.Manager.HandleCommand("/", cmd, func(ctx *event.MessageContext) error {
 			utils.Info("指令内容 ", ctx.GetText())
@@ -21,6 +44,17 @@ func loggerAddHandler(command []string, function func(*event.MessageContext)) {
 	}
 }
 
+// addAgentHandler 注册自然语言模式，群聊中@机器人或私聊中与课程功能相关的消息交给大模型处理
+func addAgentHandler() {
+	route := event.NewRoute("agent", event.NewHandlerAdapter(func(ctx *event.MessageContext) error {
+		utils.Info("自然语言请求 ", ctx.GetText())
+		agent.Agent(ctx)
+		return nil
+	}))
+	route.Match(event.NewCustomMatcher(agent.Match))
+	event.Manager.AddRoute(route)
+}
+
 func RegisterCustomLogic() {
 	if event.Manager == nil {
 		utils.Error("Logicevent.Manager 未初始化")
@@ -30,7 +64,35 @@ func RegisterCustomLogic() {
 	loggerAddHandler([]string{"login", "登录"}, login.Login)
 	loggerAddHandler([]string{"logout", "退登"}, logout.Logout)
 	loggerAddHandler([]string{"download", "下载"}, download.Download)
+	loggerAddGroupHandler([]string{"pull", "同步"}, pull.Pull)
+	loggerAddHandler([]string{"search", "搜索"}, search.Search)
+	loggerAddGroupHandler([]string{"subscribe", "订阅"}, subscribe.Subscribe)
+	loggerAddGroupHandler([]string{"unsubscribe", "取消订阅"}, subscribe.Unsubscribe)
+	loggerAddGroupHandler([]string{"subscriptions", "查看订阅"}, subscribe.Subscriptions)
 	loggerAddHandler([]string{"help", "帮助"}, help.Help)
+	loggerAddHandler([]string{"courses", "课程"}, courses.Courses)
+	loggerAddHandler([]string{"rollcall", "签到"}, rollcall.Rollcall)
+	loggerAddHandler([]string{"deadline", "作业"}, deadline.Deadline)
+	loggerAddHandler([]string{"score", "成绩"}, score.Score)
+	loggerAddHandler([]string{"notice", "公告"}, notice.Notice)
+	loggerAddHandler([]string{"calendar", "日历"}, calendar.Calendar)
+	loggerAddHandler([]string{"usage", "用量"}, usage.Usage)
+	addAgentHandler()
+
+	rollcall.StartWatcher()
+	deadline.StartScheduler()
+	subscribe.StartFeed()
+	notice.StartFeed()
 
 	utils.Info("自定义逻辑注册完成")
 }
+
+// StopCustomLogic 停止自定义逻辑中的后台任务
+func StopCustomLogic() {
+	rollcall.StopWatcher()
+	deadline.StopScheduler()
+	subscribe.StopFeed()
+	notice.StopFeed()
+
+	utils.Info("自定义逻辑后台任务已停止")
+}
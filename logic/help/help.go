@@ -3,7 +3,6 @@ package help
 import (
 	"github.com/LagrangeDev/LagrangeGo/message"
 	"github.com/vintcessun/XMU-CM-Bot/event"
-	"github.com/vintcessun/XMU-CM-Bot/tools"
 	"github.com/vintcessun/XMU-CM-Bot/utils"
 )
 
@@ -11,12 +10,25 @@ func Help(ctx *event.MessageContext) {
 	utils.Info("处理help指令")
 	defer utils.Info("处理结束help指令")
 
-	msg := ctx.AssertGroupMessage()
-	tools.Login.Delete(msg.Sender.Uin)
 	ctx.SendMessage([]message.IMessageElement{message.NewText(`帮助信息：
 	/login - 登录
 	/logout - 登出
-	/download - 下载课程文件
-	/search - 搜索所有文件根据关键词
+	/courses [current|all|<学年> <学期>] - 按学期列出课程
+	/download <课程> [--ext pdf,pptx] [--activity 关键词] [--latest N] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--zip|--sync] [--list] - 下载课程文件，--list 先列出文件再回复序号选择，--zip 打包为一个压缩包上传，--sync 只上传课程文件夹中缺少或变化的文件(仅群聊)，也可以直接说"只要第三章的PPT"
+	/pull <课程> - 将课程文件同步到群文件中的课程文件夹，只上传缺少或变化的文件
+	/search <关键词> - 搜索所有文件根据关键词，回复序号或使用 /search <序号> 获取文件
+	/subscribe <课程> [--upload] - 本群订阅课程资料和公告更新，--upload 自动上传新文件
+	/unsubscribe [课程] - 取消本群的课程订阅
+	/subscriptions - 查看本群订阅的课程
+	/rollcall [on|off] - 查看进行中的签到，开启或关闭签到提醒
+	/rollcall <签到码> [序号] - 私聊完成数字签到，有多个数字签到时用序号指定
+	/rollcall <纬度,经度> - 完成雷达签到
+	/deadline [on|off] - 查看未截止的作业考试，开启或关闭截止提醒
+	/score [all|课程] - 私聊发送本学期、全部或指定课程的成绩
+	/notice [课程|on|off] - 查看课程公告，开启或关闭本学期课程公告推送
+	/calendar - 私聊导出本学期课程表和截止时间为 .ics 日历文件
+	/usage [天数] - 查看自己和本群的大模型用量及每日额度，默认统计今日
+	除 /pull 和订阅相关指令外都可以私聊使用，临时会话中的文件会上传到群文件的"临时会话文件"文件夹
+	群聊中@机器人或私聊直接发送与课程、作业、成绩等相关的消息即可用自然语言提问，如"帮我下载高数最新的课件"、"这周有什么作业"
 	本项目仓库 https://github.com/vintcessun/XMU-CM-Bot`)})
 }
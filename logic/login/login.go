@@ -186,13 +186,16 @@ func QrLogin(ctx *event.MessageContext) (string, error) {
 }
 
 func checkAndLogin(ctx *event.MessageContext) error {
-	msg := ctx.AssertGroupMessage()
+	sender, ok := ctx.GetSender()
+	if !ok {
+		return errors.New("无法获取发送者")
+	}
 	session, err := QrLogin(ctx)
 	if err != nil {
 		ctx.SendMessage([]message.IMessageElement{message.NewText(fmt.Sprintf("登录异常 %v", err))})
 		return err
 	}
-	tools.Login.Insert(msg.Sender.Uin, session)
+	tools.Login.Insert(sender.Uin, session)
 	ctx.SendMessage([]message.IMessageElement{message.NewText("登录成功，数据已保存")})
 	return nil
 }
@@ -201,8 +204,11 @@ func Login(ctx *event.MessageContext) {
 	utils.Info("处理login指令")
 	defer utils.Info("处理结束login指令")
 
-	msg := ctx.AssertGroupMessage()
-	session, ok := tools.Login.Get(msg.Sender.Uin)
+	sender, ok := ctx.GetSender()
+	if !ok {
+		return
+	}
+	session, ok := tools.Login.Get(sender.Uin)
 
 	isSend := false
 
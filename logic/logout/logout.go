@@ -11,7 +11,13 @@ func Logout(ctx *event.MessageContext) {
 	utils.Info("处理logout指令")
 	defer utils.Info("处理结束logout指令")
 
-	msg := ctx.AssertGroupMessage()
-	tools.Login.Delete(msg.Sender.Uin)
+	sender, ok := ctx.GetSender()
+	if !ok {
+		return
+	}
+	tools.Login.Delete(sender.Uin)
+	if err := tools.DeleteUserDeadlineReminders(sender.Uin); err != nil {
+		utils.Warn("删除截止提醒失败 ", err)
+	}
 	ctx.SendMessage([]message.IMessageElement{message.NewText("已删除session")})
 }
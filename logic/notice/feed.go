@@ -0,0 +1,127 @@
+package notice
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var noticePollDelay = 30 * time.Minute
+
+type noticeFeed struct {
+	loop utils.Loop
+}
+
+var feed noticeFeed
+
+// forwardCourse 将课程的新公告推送给目标，第一次检查时只记录不推送
+func forwardCourse(target tools.NotifyTarget, course *tools.FormatCourseInside, client *resty.Client) {
+	announcements, err := tools.GetCourseAnnouncements(course, client)
+	if err != nil {
+		utils.Warn("获取课程公告失败 ", course.Name, " ", err)
+		return
+	}
+
+	if !tools.IsAnnouncementCourseInitialized(target, course.Id) {
+		if err := tools.MarkAnnouncementsSeen(target, course.Id, announcements); err != nil {
+			utils.Warn("保存公告推送记录失败 ", err)
+		}
+		return
+	}
+
+	var fresh []*tools.Announcement
+	for _, announcement := range announcements {
+		if !tools.IsAnnouncementSeen(target, announcement) {
+			fresh = append(fresh, announcement)
+		}
+	}
+	if len(fresh) == 0 {
+		return
+	}
+
+	var data []string
+	for _, announcement := range fresh {
+		data = append(data, announcement.ToString())
+	}
+	err = event.Manager.Notify(target, []message.IMessageElement{
+		message.NewText(fmt.Sprintf("课程 %s 有新公告\n%s", course.Name, strings.Join(data, "\n\n"))),
+	})
+	if err != nil {
+		utils.Warn("推送课程公告失败 ", err)
+		return
+	}
+
+	if err := tools.MarkAnnouncementsSeen(target, course.Id, fresh); err != nil {
+		utils.Warn("保存公告推送记录失败 ", err)
+	}
+}
+
+func (f *noticeFeed) pollSubscriptions() {
+	subscriptions, err := tools.GetAllSubscriptions()
+	if err != nil {
+		utils.Warn("读取订阅失败 ", err)
+		return
+	}
+	for _, subscription := range subscriptions {
+		session, ok := tools.Login.Get(subscription.OwnerUin)
+		if !ok || !tools.CheckSession.CheckSession(session) {
+			continue
+		}
+		client := utils.GetSessionClient(session)
+		course := tools.FormatCourseInside{Id: subscription.CourseId, Name: subscription.CourseName}
+		forwardCourse(tools.NotifyTarget{GroupUin: subscription.GroupUin}, &course, client)
+	}
+}
+
+func (f *noticeFeed) pollUsers() {
+	tools.Login.Range(func(uin uint32, session string) bool {
+		setting, ok := tools.GetAnnouncementSetting(uin)
+		if !ok || !setting.Enabled {
+			return true
+		}
+		if !tools.CheckSession.CheckSession(session) {
+			return true
+		}
+
+		client := utils.GetSessionClient(session)
+		courseData, err := tools.GetCourseData(client)
+		if err != nil {
+			utils.Warn("获取课程信息失败 ", err)
+			return true
+		}
+		for _, course := range *tools.GetCurrentCourseData(courseData) {
+			forwardCourse(setting.Target, course, client)
+		}
+		return true
+	})
+}
+
+func (f *noticeFeed) poll() {
+	f.pollSubscriptions()
+	f.pollUsers()
+}
+
+// StartFeed 启动课程公告推送任务
+func StartFeed() {
+	if !feed.loop.Start(noticePollDelay, feed.poll) {
+		utils.Warn("课程公告任务正在运行")
+		return
+	}
+
+	utils.Info("课程公告任务已启动")
+}
+
+// StopFeed 停止课程公告推送任务
+func StopFeed() {
+	if !feed.loop.Stop() {
+		return
+	}
+
+	utils.Info("课程公告任务已停止")
+}
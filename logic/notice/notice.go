@@ -0,0 +1,102 @@
+package notice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var maxNoticeResult = 5
+
+func setFeed(ctx *event.MessageContext, enabled bool) ([]message.IMessageElement, error) {
+	target := ctx.GetNotifyTarget()
+	err := tools.SetAnnouncementSetting(target.Uin, &tools.AnnouncementSetting{Enabled: enabled, Target: target})
+	if err != nil {
+		utils.Warn("保存公告推送设置失败 ", err)
+		return nil, errors.New("保存设置失败")
+	}
+
+	if enabled {
+		return []message.IMessageElement{message.NewText(fmt.Sprintf("已开启本学期课程公告推送，推送至%s", target.ToString()))}, nil
+	}
+	return []message.IMessageElement{message.NewText("已关闭课程公告推送")}, nil
+}
+
+func noticeFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	args := ctx.GetArgs()
+	if len(args) > 0 {
+		switch args[0] {
+		case "on", "开启":
+			return setFeed(ctx, true)
+		case "off", "关闭":
+			return setFeed(ctx, false)
+		}
+	}
+
+	client := utils.GetSessionClient(session)
+	var courses tools.FormatCourseData
+	if len(args) == 0 {
+		courseData, err := tools.GetCourseData(client)
+		if err != nil {
+			utils.Warn("获取课程信息失败 ", err)
+			return nil, errors.New("获取课程信息失败")
+		}
+		courses = *tools.GetCurrentCourseData(courseData)
+	} else {
+		course, err := ctx.GetCommandCourse(ctx.GetText(), client)
+		if err != nil {
+			return nil, err
+		}
+		courses = tools.FormatCourseData{course}
+	}
+
+	var announcements []*tools.Announcement
+	for _, course := range courses {
+		data, err := tools.GetCourseAnnouncements(course, client)
+		if err != nil {
+			utils.Warn("获取课程公告失败 ", course.Name, " ", err)
+			continue
+		}
+		announcements = append(announcements, data...)
+	}
+	if len(announcements) == 0 {
+		return []message.IMessageElement{message.NewText("没有找到课程公告")}, nil
+	}
+
+	tools.SortAnnouncements(announcements)
+	if len(announcements) > maxNoticeResult {
+		announcements = announcements[:maxNoticeResult]
+	}
+	var data []string
+	for _, announcement := range announcements {
+		data = append(data, announcement.ToString())
+	}
+	return []message.IMessageElement{message.NewText("最新的课程公告如下:\n" + strings.Join(data, "\n\n"))}, nil
+}
+
+func Notice(ctx *event.MessageContext) {
+	utils.Info("处理notice指令")
+	defer utils.Info("处理结束notice指令")
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := noticeFunc(session, ctx)
+	if ctx.AskCourse(err, Notice) {
+		return
+	}
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
@@ -1,31 +1,47 @@
 package pull
 
 import (
+	"errors"
+
 	"github.com/LagrangeDev/LagrangeGo/message"
 	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/logic/download"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
 	"github.com/vintcessun/XMU-CM-Bot/utils"
 )
 
-func pullFunc(ctx *event.MessageContext) ([]message.IMessageElement, error) {
-	msg := ctx.AssertGroupMessage()
+func pullFunc(session string, command string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
 
-	fs, err := ctx.Client.GetGroupFileSystemInfo(msg.GroupUin)
+	course, err := ctx.GetCommandCourse(command, client)
 	if err != nil {
 		return nil, err
 	}
+
+	files, err := tools.GetCourseActivities(course.Id, client)
+	if err != nil {
+		utils.Warn("获取文件失败 ", err)
+		return nil, errors.New("获取文件失败")
+	}
+
+	return download.SyncFiles(course, *files, client, ctx)
 }
 
 func Pull(ctx *event.MessageContext) {
 	utils.Info("处理pull指令")
 	defer utils.Info("处理结束pull指令")
 
-	_, ok := ctx.AssertGroupAndRejectExpired()
+	command := ctx.GetText()
+
+	session, ok := ctx.AssertGroupAndRejectExpired()
 	if !ok {
 		return
 	}
 
-	result, err := pullFunc(ctx)
-
+	result, err := pullFunc(session, command, ctx)
+	if ctx.AskCourse(err, Pull) {
+		return
+	}
 	if err != nil {
 		utils.Error("处理失败: ", err)
 		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
@@ -0,0 +1,198 @@
+package rollcall
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var numberCodeRegex = regexp.MustCompile(`^\d{4}$`)
+
+func setWatch(ctx *event.MessageContext, enabled bool) ([]message.IMessageElement, error) {
+	target := ctx.GetNotifyTarget()
+	setting := tools.RollcallSetting{Enabled: enabled, Target: target}
+	err := tools.SetRollcallSetting(target.Uin, &setting)
+	if err != nil {
+		utils.Warn("保存签到提醒设置失败 ", err)
+		return nil, errors.New("保存设置失败")
+	}
+
+	if enabled {
+		return []message.IMessageElement{message.NewText(fmt.Sprintf("已开启签到提醒，推送至%s", target.ToString()))}, nil
+	}
+	return []message.IMessageElement{message.NewText("已关闭签到提醒")}, nil
+}
+
+func listRollcalls(session string, uin uint32) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	rollcalls, err := tools.GetRollcalls(client)
+	if err != nil {
+		utils.Warn("获取签到失败 ", err)
+		return nil, errors.New("获取签到失败")
+	}
+
+	state := "未开启"
+	if setting, ok := tools.GetRollcallSetting(uin); ok && setting.Enabled {
+		state = "已开启，推送至" + setting.Target.ToString()
+	}
+
+	var open []*tools.Rollcall
+	for _, rollcall := range rollcalls {
+		if rollcall.IsOpen() {
+			open = append(open, rollcall)
+		}
+	}
+
+	if len(open) == 0 {
+		return []message.IMessageElement{message.NewText(fmt.Sprintf("当前没有进行中的签到\n签到提醒: %s", state))}, nil
+	}
+	return []message.IMessageElement{message.NewText(fmt.Sprintf("进行中的签到如下:\n%s\n签到提醒: %s", tools.RollcallListString(open), state))}, nil
+}
+
+// parseLocation 解析 "纬度,经度" 或 "纬度 经度" 形式的定位
+func parseLocation(args []string) (float64, float64, bool) {
+	parts := strings.FieldsFunc(strings.Join(args, " "), func(r rune) bool {
+		return r == ',' || r == '，' || r == ' '
+	})
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	latitude, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil || latitude < -90 || latitude > 90 {
+		return 0, 0, false
+	}
+	longitude, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil || longitude < -180 || longitude > 180 {
+		return 0, 0, false
+	}
+	return latitude, longitude, true
+}
+
+func answerRollcalls(session string, rollcallType tools.RollcallType, answer func(client *resty.Client, rollcall *tools.Rollcall) error) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	rollcalls, err := tools.GetRollcalls(client)
+	if err != nil {
+		utils.Warn("获取签到失败 ", err)
+		return nil, errors.New("获取签到失败")
+	}
+
+	var result []string
+	for _, rollcall := range rollcalls {
+		if !rollcall.IsOpen() || rollcall.Type != rollcallType {
+			continue
+		}
+		if rollcall.Answered {
+			result = append(result, fmt.Sprintf("%s: 已签到，跳过", rollcall.Course))
+			continue
+		}
+		err := answer(client, rollcall)
+		if err != nil {
+			utils.Warn("签到失败 ", rollcall.Id, " ", err)
+			result = append(result, fmt.Sprintf("%s: 签到失败 %s", rollcall.Course, err.Error()))
+			continue
+		}
+		result = append(result, fmt.Sprintf("%s: 签到成功", rollcall.Course))
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("当前没有进行中的%s", rollcallType)
+	}
+	return []message.IMessageElement{message.NewText(strings.Join(result, "\n"))}, nil
+}
+
+// answerNumberRollcall 签到码只提交给一个数字签到，有多个进行中的数字签到时需要用户指定序号
+func answerNumberRollcall(session string, code string, args []string) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	rollcalls, err := tools.GetRollcalls(client)
+	if err != nil {
+		utils.Warn("获取签到失败 ", err)
+		return nil, errors.New("获取签到失败")
+	}
+
+	var open []*tools.Rollcall
+	for _, rollcall := range rollcalls {
+		if rollcall.IsOpen() && rollcall.Type == tools.RollcallTypeNumber && !rollcall.Answered {
+			open = append(open, rollcall)
+		}
+	}
+
+	var rollcall *tools.Rollcall
+	switch {
+	case len(open) == 0:
+		return nil, fmt.Errorf("当前没有未完成的%s", tools.RollcallTypeNumber)
+	case len(args) > 0:
+		index, err := strconv.Atoi(args[0])
+		if err != nil || index < 1 || index > len(open) {
+			return nil, fmt.Errorf("序号应为 1 到 %d 之间的整数", len(open))
+		}
+		rollcall = open[index-1]
+	case len(open) == 1:
+		rollcall = open[0]
+	default:
+		return []message.IMessageElement{message.NewText(fmt.Sprintf("当前有多个进行中的数字签到，请使用 /rollcall %s <序号> 指定:\n%s", code, tools.RollcallListString(open)))}, nil
+	}
+
+	err = tools.AnswerNumberRollcall(client, rollcall.Id, code)
+	if err != nil {
+		utils.Warn("签到失败 ", rollcall.Id, " ", err)
+		return nil, fmt.Errorf("%s: 签到失败 %s", rollcall.Course, err.Error())
+	}
+	return []message.IMessageElement{message.NewText(fmt.Sprintf("%s: 签到成功", rollcall.Course))}, nil
+}
+
+func rollcallFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	args := ctx.GetArgs()
+	if len(args) == 0 {
+		return listRollcalls(session, ctx.GetNotifyTarget().Uin)
+	}
+
+	switch args[0] {
+	case "on", "开启":
+		return setWatch(ctx, true)
+	case "off", "关闭":
+		return setWatch(ctx, false)
+	}
+
+	if numberCodeRegex.MatchString(args[0]) {
+		// 签到码在群聊中会被其他人看到，只允许私聊和临时会话使用
+		if _, isGroup := ctx.GetGroupMessage(); isGroup {
+			return nil, errors.New("签到码在群聊中可能被泄露，请私聊使用本指令")
+		}
+		return answerNumberRollcall(session, args[0], args[1:])
+	}
+
+	if latitude, longitude, ok := parseLocation(args); ok {
+		return answerRollcalls(session, tools.RollcallTypeRadar, func(client *resty.Client, rollcall *tools.Rollcall) error {
+			return tools.AnswerRadarRollcall(client, rollcall.Id, latitude, longitude)
+		})
+	}
+
+	return nil, errors.New("未知参数，可用参数: on/off/4位签到码 [序号]/纬度,经度")
+}
+
+func Rollcall(ctx *event.MessageContext) {
+	utils.Info("处理rollcall指令")
+	defer utils.Info("处理结束rollcall指令")
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := rollcallFunc(session, ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
@@ -0,0 +1,98 @@
+package rollcall
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var rollcallPollDelay = 30 * time.Second
+
+type rollcallWatcher struct {
+	mu   sync.Mutex
+	loop utils.Loop
+	// notified 记录每个用户已经推送过的签到，避免重复推送
+	notified map[uint32]map[int]bool
+}
+
+var watcher = rollcallWatcher{notified: make(map[uint32]map[int]bool)}
+
+func (w *rollcallWatcher) checkUser(uin uint32, session string, setting *tools.RollcallSetting) {
+	client := utils.GetSessionClient(session)
+	rollcalls, err := tools.GetRollcalls(client)
+	if err != nil {
+		utils.Debug("获取签到失败 ", uin, " ", err)
+		return
+	}
+
+	w.mu.Lock()
+	notified, ok := w.notified[uin]
+	if !ok {
+		notified = make(map[int]bool)
+		w.notified[uin] = notified
+	}
+	open := make(map[int]bool)
+	var newRollcalls []*tools.Rollcall
+	for _, rollcall := range rollcalls {
+		if !rollcall.IsOpen() {
+			continue
+		}
+		open[rollcall.Id] = true
+		if rollcall.Answered || notified[rollcall.Id] {
+			continue
+		}
+		notified[rollcall.Id] = true
+		newRollcalls = append(newRollcalls, rollcall)
+	}
+	for id := range notified {
+		if !open[id] {
+			delete(notified, id)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, rollcall := range newRollcalls {
+		utils.Info("推送签到 ", uin, " ", rollcall.Id)
+		err := event.Manager.Notify(setting.Target, []message.IMessageElement{
+			message.NewText(fmt.Sprintf("有新的签到开始了\n%s", rollcall.ToString())),
+		})
+		if err != nil {
+			utils.Warn("推送签到失败 ", err)
+		}
+	}
+}
+
+func (w *rollcallWatcher) poll() {
+	tools.Login.Range(func(uin uint32, session string) bool {
+		setting, ok := tools.GetRollcallSetting(uin)
+		if !ok || !setting.Enabled {
+			return true
+		}
+		w.checkUser(uin, session, setting)
+		return true
+	})
+}
+
+// StartWatcher 启动签到监听任务
+func StartWatcher() {
+	if !watcher.loop.Start(rollcallPollDelay, watcher.poll) {
+		utils.Warn("签到监听任务正在运行")
+		return
+	}
+
+	utils.Info("签到监听任务已启动")
+}
+
+// StopWatcher 停止签到监听任务
+func StopWatcher() {
+	if !watcher.loop.Stop() {
+		return
+	}
+
+	utils.Info("签到监听任务已停止")
+}
@@ -0,0 +1,84 @@
+package score
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// maxActivityScoreCourses 超过这个数量的课程时不再获取活动成绩，每门课程需要请求两次
+var maxActivityScoreCourses = 3
+
+func scoreFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	scores, err := tools.GetCourseScores(client)
+	if err != nil {
+		utils.Warn("获取成绩失败 ", err)
+		return nil, errors.New("获取成绩失败")
+	}
+
+	args := ctx.GetArgs()
+	semester := tools.CurrentSemester()
+	keyword := ""
+	if len(args) > 0 {
+		switch args[0] {
+		case "all", "全部":
+			semester = ""
+		default:
+			semester = ""
+			keyword = strings.Join(args, " ")
+		}
+	}
+
+	scores = tools.FilterCourseScores(scores, semester, keyword)
+	if len(scores) == 0 {
+		return nil, errors.New("没有找到对应课程的成绩")
+	}
+
+	if len(scores) <= maxActivityScoreCourses {
+		for _, score := range scores {
+			activities, err := tools.GetActivityScores(score.Course.Id, client)
+			if err != nil {
+				utils.Warn("获取活动成绩失败 ", score.Course.Name, " ", err)
+				continue
+			}
+			score.Activities = activities
+		}
+	}
+
+	return []message.IMessageElement{message.NewText("成绩如下:\n" + tools.CourseScoreTableString(scores))}, nil
+}
+
+func Score(ctx *event.MessageContext) {
+	utils.Info("处理score指令")
+	defer utils.Info("处理结束score指令")
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := scoreFunc(session, ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	// 成绩属于敏感信息，只通过私聊或临时会话发送
+	if _, isGroup := ctx.GetGroupMessage(); !isGroup {
+		ctx.SendMessage(result)
+		return
+	}
+	err = event.Manager.Notify(tools.NotifyTarget{Uin: ctx.GetNotifyTarget().Uin}, result)
+	if err != nil {
+		utils.Warn("私聊发送成绩失败 ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("私聊发送成绩失败，请先添加机器人为好友")})
+		return
+	}
+	ctx.SendMessage([]message.IMessageElement{message.NewText("成绩已通过私聊发送")})
+}
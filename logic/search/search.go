@@ -1 +1,165 @@
 package search
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var maxSearchResult = 10
+var selectionTimeout = 5 * time.Minute
+
+// searchResult 最近一次的搜索结果，可以直接回复序号，也可以使用 /search 序号 选择
+type searchResult struct {
+	files  []*tools.CourseFile
+	client *resty.Client
+	expire time.Time
+}
+
+// lastResults 按照群和发送者保存最近一次的搜索结果
+var lastResults sync.Map
+
+func storeResult(target tools.NotifyTarget, result *searchResult) {
+	now := time.Now()
+	lastResults.Range(func(key, value any) bool {
+		if data, ok := value.(*searchResult); !ok || now.After(data.expire) {
+			lastResults.Delete(key)
+		}
+		return true
+	})
+	lastResults.Store(target, result)
+}
+
+func loadResult(target tools.NotifyTarget) (*searchResult, bool) {
+	value, ok := lastResults.Load(target)
+	if !ok {
+		return nil, false
+	}
+	result, ok := value.(*searchResult)
+	if !ok || time.Now().After(result.expire) {
+		return nil, false
+	}
+	return result, true
+}
+
+func (r *searchResult) pick(indices []int, ctx *event.MessageContext) []message.IMessageElement {
+	var selected []*tools.CourseFile
+	for _, index := range indices {
+		selected = append(selected, r.files[index-1])
+	}
+	return pickFiles(r.client, selected, ctx)
+}
+
+// SearchFiles 搜索所有课程的文件，回复序号后发送对应文件
+func SearchFiles(session string, keywords []string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	client := utils.GetSessionClient(session)
+	files, err := tools.GetAllCourseFiles(session, client)
+	if err != nil {
+		utils.Warn("获取课程文件失败 ", err)
+		return nil, errors.New("获取课程文件失败")
+	}
+
+	result := tools.SearchCourseFiles(files, keywords)
+	if len(result) == 0 {
+		return []message.IMessageElement{message.NewText("没有找到匹配的文件")}, nil
+	}
+	if len(result) > maxSearchResult {
+		result = result[:maxSearchResult]
+	}
+
+	data := &searchResult{files: result, client: client, expire: time.Now().Add(selectionTimeout)}
+	storeResult(ctx.GetNotifyTarget(), data)
+	event.Pending.SetSelection(ctx, selectionTimeout, len(result), func(reply *event.MessageContext, indices []int) {
+		utils.Info("处理search选择")
+		defer utils.Info("处理结束search选择")
+
+		reply.SendMessage(data.pick(indices, reply))
+	})
+
+	var lines []string
+	for i, file := range result {
+		lines = append(lines, fmt.Sprintf("%d. [%s] %s/%s (%s)", i+1, file.Course.Name, file.File.Activity, file.File.FileName, utils.FormatSize(file.File.Size)))
+	}
+	return []message.IMessageElement{message.NewText(fmt.Sprintf("搜索结果如下，请在 %d 分钟内回复序号或使用 /search 序号 获取文件，如 1,3:\n%s", int(selectionTimeout.Minutes()), strings.Join(lines, "\n")))}, nil
+}
+
+func pickFiles(client *resty.Client, files []*tools.CourseFile, ctx *event.MessageContext) []message.IMessageElement {
+	request := tools.Transfer.NewRequest(ctx.GetNotifyTarget())
+	var sent, failed []string
+	for _, file := range files {
+		var err error
+		request.Do(func() {
+			err = ctx.SendCourseFile(file.File, client)
+		})
+		if err == nil {
+			sent = append(sent, file.File.Name)
+			continue
+		}
+
+		utils.Warn("发送文件失败 ", err)
+		url, err := tools.FallbackURL(file.File, client)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: 发送文件失败", file.File.Name))
+		} else {
+			failed = append(failed, fmt.Sprintf("%s: 发送文件失败，下载链接为: %s", file.File.Name, url))
+		}
+	}
+
+	var data []string
+	if len(sent) > 0 {
+		data = append(data, "已发送文件:\n"+strings.Join(sent, "\n"))
+		if note := ctx.FileDeliveryNote(); note != "" {
+			data = append(data, note)
+		}
+	}
+	if len(failed) > 0 {
+		data = append(data, "发送失败:\n"+strings.Join(failed, "\n"))
+	}
+	return []message.IMessageElement{message.NewText(strings.Join(data, "\n"))}
+}
+
+func searchFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	args := ctx.GetArgs()
+	if len(args) == 0 {
+		return nil, errors.New("请输入搜索关键词")
+	}
+
+	// 有未过期的搜索结果时，/search 序号 选择其中的文件
+	if result, ok := loadResult(ctx.GetNotifyTarget()); ok && len(args) == 1 && utils.IsSelection(args[0]) {
+		indices, err := utils.ParseSelection(args[0], len(result.files))
+		if err != nil {
+			return nil, err
+		}
+		event.Pending.Delete(ctx)
+		return result.pick(indices, ctx), nil
+	}
+
+	return SearchFiles(session, args, ctx)
+}
+
+func Search(ctx *event.MessageContext) {
+	utils.Info("处理search指令")
+	defer utils.Info("处理结束search指令")
+
+	session, ok := ctx.RejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := searchFunc(session, ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
@@ -0,0 +1,185 @@
+package subscribe
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/LagrangeDev/LagrangeGo/client"
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	message2 "github.com/vintcessun/XMU-CM-Bot/message"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var feedPollDelay = 30 * time.Minute
+var sendFileRetryTime = 3
+
+type subscriptionFeed struct {
+	loop utils.Loop
+}
+
+var feed subscriptionFeed
+
+// uploadChanges 将新增和修改的文件上传到群文件中的课程文件夹
+func uploadChanges(qqClient *client.QQClient, subscription *tools.Subscription, files tools.FormatFileData, restyClient *resty.Client) []string {
+	folder, err := message2.FindOrCreateGroupFolder(qqClient, subscription.GroupUin, subscription.CourseName)
+	if err != nil {
+		utils.Warn("获取课程文件夹失败 ", err)
+		return []string{"获取课程文件夹失败"}
+	}
+
+	groupFiles, _, err := qqClient.ListGroupFilesByFolder(subscription.GroupUin, folder)
+	if err != nil {
+		utils.Warn("获取群文件失败 ", err)
+		return []string{"获取群文件失败"}
+	}
+	var remote []*tools.RemoteFile
+	for _, file := range groupFiles {
+		remote = append(remote, &tools.RemoteFile{Id: file.FileID, Name: file.FileName, Size: int64(file.FileSize)})
+	}
+
+	plan := tools.PlanFolderSync(remote, files)
+	request := tools.Transfer.NewRequest(tools.NotifyTarget{GroupUin: subscription.GroupUin})
+	upload := func(file *tools.FormatFileInside) error {
+		var err error
+		for range sendFileRetryTime {
+			request.Do(func() {
+				var path string
+				var release func()
+				path, release, err = tools.FileCache.Fetch(file, restyClient)
+				if err != nil {
+					return
+				}
+				defer release()
+				err = qqClient.SendGroupFile(subscription.GroupUin, path, file.Name, folder)
+			})
+			if err == nil {
+				return nil
+			}
+			utils.Warn("发送失败，重试 ", err)
+		}
+		return err
+	}
+
+	var failed []string
+	for _, file := range plan.Missing {
+		if err := upload(file); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", file.Name, err.Error()))
+		}
+	}
+	// 先上传新文件，成功后再删除旧文件，上传失败时保留旧文件
+	for _, change := range plan.Changed {
+		if err := upload(change.File); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", change.File.Name, err.Error()))
+			continue
+		}
+		if err := qqClient.DeleteGroupFile(subscription.GroupUin, change.Remote.Id); err != nil {
+			utils.Warn("删除旧文件失败 ", err)
+		}
+	}
+	return failed
+}
+
+func (f *subscriptionFeed) checkSubscription(subscription *tools.Subscription) {
+	session, ok := tools.Login.Get(subscription.OwnerUin)
+	if !ok || !tools.CheckSession.CheckSession(session) {
+		utils.Debug("订阅者登录已失效 ", subscription.OwnerUin)
+		return
+	}
+
+	client := utils.GetSessionClient(session)
+	files, err := tools.GetCourseActivities(subscription.CourseId, client)
+	if err != nil {
+		utils.Warn("获取订阅课程文件失败 ", err)
+		return
+	}
+
+	snapshot, ok := tools.GetSubscriptionSnapshot(subscription)
+	if !ok {
+		if err := tools.PutSubscriptionSnapshot(subscription, tools.NewSubscriptionSnapshot(*files)); err != nil {
+			utils.Warn("保存订阅快照失败 ", err)
+		}
+		return
+	}
+
+	added, modified := tools.DiffSubscriptionSnapshot(snapshot, *files)
+	if len(added) == 0 && len(modified) == 0 {
+		return
+	}
+
+	result := []string{fmt.Sprintf("订阅的课程 %s 有更新", subscription.CourseName)}
+	if len(added) > 0 {
+		var names []string
+		for _, file := range added {
+			names = append(names, file.Name)
+		}
+		result = append(result, "新增文件:\n"+strings.Join(names, "\n"))
+	}
+	if len(modified) > 0 {
+		var names []string
+		for _, file := range modified {
+			names = append(names, file.Name)
+		}
+		result = append(result, "修改文件:\n"+strings.Join(names, "\n"))
+	}
+	saveSnapshot := func() {
+		if err := tools.PutSubscriptionSnapshot(subscription, tools.NewSubscriptionSnapshot(*files)); err != nil {
+			utils.Warn("保存订阅快照失败 ", err)
+		}
+	}
+	if subscription.AutoUpload {
+		failed := uploadChanges(event.Manager.GetClient(), subscription, append(added, modified...), client)
+		if len(failed) == 0 {
+			result = append(result, "已自动上传到群文件")
+		} else {
+			result = append(result, "自动上传失败如下:\n"+strings.Join(failed, "\n"))
+		}
+		// 已经上传过的文件不再重复上传，推送失败时也保存快照
+		saveSnapshot()
+	}
+
+	err = event.Manager.Notify(tools.NotifyTarget{GroupUin: subscription.GroupUin}, []message.IMessageElement{
+		message.NewText(strings.Join(result, "\n")),
+	})
+	if err != nil {
+		utils.Warn("推送订阅更新失败 ", err)
+		return
+	}
+
+	if !subscription.AutoUpload {
+		saveSnapshot()
+	}
+}
+
+func (f *subscriptionFeed) poll() {
+	subscriptions, err := tools.GetAllSubscriptions()
+	if err != nil {
+		utils.Warn("读取订阅失败 ", err)
+		return
+	}
+	for _, subscription := range subscriptions {
+		f.checkSubscription(subscription)
+	}
+}
+
+// StartFeed 启动课程订阅更新任务
+func StartFeed() {
+	if !feed.loop.Start(feedPollDelay, feed.poll) {
+		utils.Warn("课程订阅任务正在运行")
+		return
+	}
+
+	utils.Info("课程订阅任务已启动")
+}
+
+// StopFeed 停止课程订阅更新任务
+func StopFeed() {
+	if !feed.loop.Stop() {
+		return
+	}
+
+	utils.Info("课程订阅任务已停止")
+}
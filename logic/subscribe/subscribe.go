@@ -0,0 +1,162 @@
+package subscribe
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+var autoUploadFlag = "--upload"
+
+func subscribeFunc(session string, ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	msg := ctx.AssertGroupMessage()
+	command := ctx.GetText()
+	autoUpload := strings.Contains(command, autoUploadFlag)
+	command = strings.ReplaceAll(command, autoUploadFlag, "")
+
+	client := utils.GetSessionClient(session)
+	course, err := ctx.GetCommandCourse(command, client)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := tools.GetCourseActivities(course.Id, client)
+	if err != nil {
+		utils.Warn("获取文件失败 ", err)
+		return nil, errors.New("获取文件失败")
+	}
+
+	subscription := tools.Subscription{
+		GroupUin:   msg.GroupUin,
+		CourseId:   course.Id,
+		CourseName: course.Name,
+		OwnerUin:   msg.Sender.Uin,
+		AutoUpload: autoUpload,
+	}
+	err = tools.PutSubscription(&subscription)
+	if err != nil {
+		utils.Warn("保存订阅失败 ", err)
+		return nil, errors.New("保存订阅失败")
+	}
+	err = tools.PutSubscriptionSnapshot(&subscription, tools.NewSubscriptionSnapshot(*files))
+	if err != nil {
+		utils.Warn("保存订阅快照失败 ", err)
+	}
+
+	return []message.IMessageElement{message.NewText(fmt.Sprintf("已订阅 %s，当前共 %d 个文件，有更新时将推送到本群", subscription.ToString(), len(*files)))}, nil
+}
+
+func unsubscribeFunc(ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	msg := ctx.AssertGroupMessage()
+	subscriptions, err := tools.GetGroupSubscriptions(msg.GroupUin)
+	if err != nil {
+		utils.Warn("读取订阅失败 ", err)
+		return nil, errors.New("读取订阅失败")
+	}
+	if len(subscriptions) == 0 {
+		return nil, errors.New("本群没有订阅任何课程")
+	}
+
+	args := ctx.GetArgs()
+	var matched []*tools.Subscription
+	if len(args) == 0 {
+		if len(subscriptions) > 1 {
+			return nil, errors.New("本群订阅了多门课程，请指定要取消订阅的课程")
+		}
+		matched = subscriptions
+	} else {
+		keyword := strings.Join(args, " ")
+		for _, subscription := range subscriptions {
+			if strings.Contains(subscription.CourseName, keyword) || strconv.Itoa(subscription.CourseId) == keyword {
+				matched = append(matched, subscription)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return nil, errors.New("没有找到对应的订阅")
+	}
+
+	var names []string
+	for _, subscription := range matched {
+		if err := tools.DeleteSubscription(subscription); err != nil {
+			utils.Warn("删除订阅失败 ", err)
+			return nil, errors.New("删除订阅失败")
+		}
+		names = append(names, subscription.CourseName)
+	}
+	return []message.IMessageElement{message.NewText("已取消订阅 " + strings.Join(names, "、"))}, nil
+}
+
+func subscriptionsFunc(ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	msg := ctx.AssertGroupMessage()
+	subscriptions, err := tools.GetGroupSubscriptions(msg.GroupUin)
+	if err != nil {
+		utils.Warn("读取订阅失败 ", err)
+		return nil, errors.New("读取订阅失败")
+	}
+	if len(subscriptions) == 0 {
+		return []message.IMessageElement{message.NewText("本群没有订阅任何课程")}, nil
+	}
+
+	var data []string
+	for i, subscription := range subscriptions {
+		data = append(data, fmt.Sprintf("%d. %s", i+1, subscription.ToString()))
+	}
+	return []message.IMessageElement{message.NewText("本群订阅的课程如下:\n" + strings.Join(data, "\n"))}, nil
+}
+
+func Subscribe(ctx *event.MessageContext) {
+	utils.Info("处理subscribe指令")
+	defer utils.Info("处理结束subscribe指令")
+
+	session, ok := ctx.AssertGroupAndRejectExpired()
+	if !ok {
+		return
+	}
+
+	result, err := subscribeFunc(session, ctx)
+	if ctx.AskCourse(err, Subscribe) {
+		return
+	}
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
+
+func Unsubscribe(ctx *event.MessageContext) {
+	utils.Info("处理unsubscribe指令")
+	defer utils.Info("处理结束unsubscribe指令")
+
+	result, err := unsubscribeFunc(ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
+
+func Subscriptions(ctx *event.MessageContext) {
+	utils.Info("处理subscriptions指令")
+	defer utils.Info("处理结束subscriptions指令")
+
+	result, err := subscriptionsFunc(ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
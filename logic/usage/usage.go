@@ -0,0 +1,89 @@
+package usage
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/LagrangeDev/LagrangeGo/message"
+	"github.com/vintcessun/XMU-CM-Bot/event"
+	"github.com/vintcessun/XMU-CM-Bot/tools"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// maxUsageDays 最多统计的天数
+var maxUsageDays = 31
+
+func quotaString(tokens, requests int64) string {
+	var data []string
+	if requests > 0 {
+		data = append(data, fmt.Sprintf("%d 次请求", requests))
+	}
+	if tokens > 0 {
+		data = append(data, fmt.Sprintf("%d tokens", tokens))
+	}
+	if len(data) == 0 {
+		return "不限"
+	}
+	return strings.Join(data, "，")
+}
+
+func usageFunc(ctx *event.MessageContext) ([]message.IMessageElement, error) {
+	days := 1
+	if args := ctx.GetArgs(); len(args) > 0 {
+		var err error
+		days, err = strconv.Atoi(args[0])
+		if err != nil || days < 1 || days > maxUsageDays {
+			return nil, fmt.Errorf("天数应为 1 到 %d 之间的整数", maxUsageDays)
+		}
+	}
+
+	target := ctx.GetNotifyTarget()
+	if target.Uin == 0 {
+		return nil, errors.New("无法获取用户信息")
+	}
+	quota := tools.GetLLMQuota()
+
+	period := "今日"
+	if days > 1 {
+		period = fmt.Sprintf("最近 %d 天", days)
+	}
+	data := []string{period + "的大模型用量:"}
+	data = append(data, "个人: "+tools.GetUserLLMUsage(target.Uin, days).ToString())
+	if target.GroupUin != 0 {
+		data = append(data, "本群: "+tools.GetGroupLLMUsage(target.GroupUin, days).ToString())
+	}
+
+	models, err := tools.GetModelLLMUsage(days)
+	if err != nil {
+		utils.Warn("获取模型用量失败 ", err)
+		return nil, errors.New("获取模型用量失败")
+	}
+	if len(models) > 0 {
+		data = append(data, "各模型:")
+		for _, model := range models {
+			data = append(data, "  "+model.Model+": "+model.Stat.ToString())
+		}
+	}
+
+	data = append(data, "每日额度:", "  个人: "+quotaString(quota.UserTokens, quota.UserRequests))
+	if target.GroupUin != 0 {
+		data = append(data, "  本群: "+quotaString(quota.GroupTokens, quota.GroupRequests))
+	}
+	return []message.IMessageElement{message.NewText(strings.Join(data, "\n"))}, nil
+}
+
+func Usage(ctx *event.MessageContext) {
+	utils.Info("处理usage指令")
+	defer utils.Info("处理结束usage指令")
+
+	result, err := usageFunc(ctx)
+	if err != nil {
+		utils.Error("处理失败: ", err)
+		ctx.SendMessage([]message.IMessageElement{message.NewText("处理失败: " + err.Error())})
+		return
+	}
+
+	ctx.SendMessage(result)
+}
@@ -41,6 +41,7 @@ func main() {
 	defer bot.Client().Release()
 	defer bot.Dumpsig()
 	defer tools.DeInitialize()
+	defer logic.StopCustomLogic()
 
 	// setup the main stop channel
 	mc := make(chan os.Signal, 2)
@@ -7,7 +7,6 @@ import (
 
 	"github.com/LagrangeDev/LagrangeGo/client"
 	"github.com/LagrangeDev/LagrangeGo/message"
-	lagio "github.com/LagrangeDev/LagrangeGo/utils/io"
 	"github.com/go-resty/resty/v2"
 	"github.com/vintcessun/XMU-CM-Bot/utils"
 )
@@ -64,11 +63,23 @@ func (m *TempMessage) GetMessage() interface{} { return m.message }
 func (m *TempMessage) SendMessage(client *client.QQClient, elements []message.IMessageElement) (interface{}, error) {
 	return client.SendTempMessage(m.message.GroupUin, m.message.Sender.Uin, elements)
 }
+
+// TempFileFolderName 临时会话无法直接发送文件，文件上传到来源群的这个文件夹中，不放在群文件根目录
+var TempFileFolderName = "临时会话文件"
+
 func (m *TempMessage) SendFileLocal(client *client.QQClient, localFilePath, filename string, folderId ...string) error {
-	return errors.New("临时聊天无法发送消息")
+	folder, err := FindOrCreateGroupFolder(client, m.message.GroupUin, TempFileFolderName)
+	if err != nil {
+		return err
+	}
+	return client.SendGroupFile(m.message.GroupUin, localFilePath, filename, folder)
 }
 func (m *TempMessage) SendFileURL(client *client.QQClient, url, filename string, restyClient *resty.Client, folderId ...string) error {
-	return errors.New("临时聊天无法发送消息")
+	folder, err := FindOrCreateGroupFolder(client, m.message.GroupUin, TempFileFolderName)
+	if err != nil {
+		return err
+	}
+	return UploadGroupFileURL(client, m.message.GroupUin, url, filename, restyClient, folder)
 }
 func (m *TempMessage) GetMessageElements() []message.IMessageElement {
 	return m.message.Elements
@@ -87,23 +98,11 @@ func (m *GroupMessage) SendMessage(client *client.QQClient, elements []message.I
 	return client.SendGroupMessage(m.message.GroupUin, append([]message.IMessageElement{message.NewAt(m.message.Sender.Uin), message.NewText(" \n")}, elements...))
 }
 func (m *GroupMessage) SendFileLocal(client *client.QQClient, localFilePath, filename string, folderId ...string) error {
-	folder := lagio.Ternary(len(folderId) >= 1 && folderId[0] != "", folderId[0], "/")
+	folder := groupFolder(folderId)
 	return client.SendGroupFile(m.message.GroupUin, localFilePath, filename, folder)
 }
 func (m *GroupMessage) SendFileURL(client *client.QQClient, url, filename string, restyClient *resty.Client, folderId ...string) error {
-	deferFunc, fileElem, err := getTempFileElementURL(url, filename, restyClient)
-	defer deferFunc()
-	if err != nil {
-		return err
-	}
-
-	folder := lagio.Ternary(len(folderId) >= 1 && folderId[0] != "", folderId[0], "/")
-	_, err = client.UploadGroupFile(m.message.GroupUin, fileElem, folder)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return UploadGroupFileURL(client, m.message.GroupUin, url, filename, restyClient, folderId...)
 }
 
 func (m *GroupMessage) GetMessageElements() []message.IMessageElement {
@@ -132,6 +131,60 @@ func NewMessage(msg any) Message {
 	}
 }
 
+// groupFolder 返回上传的群文件夹，没有指定时为根目录
+func groupFolder(folderId []string) string {
+	if len(folderId) == 0 || folderId[0] == "" {
+		return "/"
+	}
+	return folderId[0]
+}
+
+// UploadGroupFileURL 下载链接对应的文件并上传到群文件
+func UploadGroupFileURL(client *client.QQClient, groupUin uint32, url, filename string, restyClient *resty.Client, folderId ...string) error {
+	deferFunc, fileElem, err := getTempFileElementURL(url, filename, restyClient)
+	defer deferFunc()
+	if err != nil {
+		return err
+	}
+
+	folder := groupFolder(folderId)
+	_, err = client.UploadGroupFile(groupUin, fileElem, folder)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// FindOrCreateGroupFolder 在群文件根目录中查找指定名称的文件夹，不存在时创建
+func FindOrCreateGroupFolder(client *client.QQClient, groupUin uint32, name string) (string, error) {
+	_, folders, err := client.ListGroupRootFiles(groupUin)
+	if err != nil {
+		return "", err
+	}
+	for _, folder := range folders {
+		if folder.FolderName == name {
+			return folder.FolderID, nil
+		}
+	}
+
+	err = client.CreateGroupFolder(groupUin, "/", name)
+	if err != nil {
+		return "", err
+	}
+
+	_, folders, err = client.ListGroupRootFiles(groupUin)
+	if err != nil {
+		return "", err
+	}
+	for _, folder := range folders {
+		if folder.FolderName == name {
+			return folder.FolderID, nil
+		}
+	}
+	return "", errors.New("未找到文件夹")
+}
+
 func getTempFileElementURL(url, filename string, client *resty.Client) (func(), *message.FileElement, error) {
 	resp, err := client.R().Get(url)
 	if err != nil {
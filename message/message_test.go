@@ -0,0 +1,37 @@
+package message
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/LagrangeDev/LagrangeGo/client"
+	"github.com/LagrangeDev/LagrangeGo/message"
+)
+
+func TestGroupFolder(t *testing.T) {
+	tests := []struct {
+		name     string
+		folderId []string
+		want     string
+	}{
+		{name: "没有指定", want: "/"},
+		{name: "空文件夹", folderId: []string{""}, want: "/"},
+		{name: "指定文件夹", folderId: []string{"/abc"}, want: "/abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := groupFolder(tt.folderId); got != tt.want {
+				t.Fatalf("文件夹 = %q, 期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 不指定文件夹发送群文件时不能panic，文件不存在时在上传前返回错误
+func TestGroupSendFileLocalWithoutFolder(t *testing.T) {
+	msg := NewMessage(&message.GroupMessage{GroupUin: 1, Sender: &message.Sender{Uin: 2}})
+	err := msg.SendFileLocal(&client.QQClient{}, filepath.Join(t.TempDir(), "missing.pdf"), "missing.pdf")
+	if err == nil {
+		t.Fatal("期望返回文件不存在的错误")
+	}
+}
@@ -0,0 +1,152 @@
+package tools
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+type APICourseBulletinCreator struct {
+	Name string `json:"name"`
+}
+
+type APICourseBulletin struct {
+	Id        int                      `json:"id"`
+	Title     string                   `json:"title"`
+	Content   string                   `json:"content"`
+	CreatedAt string                   `json:"created_at"`
+	CreatedBy APICourseBulletinCreator `json:"created_by"`
+}
+
+type APICourseBulletins struct {
+	Bulletins []*APICourseBulletin `json:"bulletins"`
+}
+
+// Announcement 课程公告
+type Announcement struct {
+	Id        int
+	CourseId  int
+	Course    string
+	Title     string
+	Content   string
+	Author    string
+	CreatedAt time.Time
+}
+
+var htmlTagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+var maxAnnouncementContent = 300
+
+func (a *Announcement) ToString() string {
+	content := []rune(a.Content)
+	if len(content) > maxAnnouncementContent {
+		content = append(content[:maxAnnouncementContent], []rune("...")...)
+	}
+	return fmt.Sprintf("[%s] %s\n发布者: %s 时间: %s\n%s", a.Course, a.Title, a.Author, a.CreatedAt.Format("2006-01-02 15:04"), string(content))
+}
+
+func stripHTML(text string) string {
+	text = strings.ReplaceAll(text, "<br>", "\n")
+	text = strings.ReplaceAll(text, "</p>", "\n")
+	text = htmlTagPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+// GetCourseAnnouncements 获取课程公告，按发布时间从新到旧排序
+func GetCourseAnnouncements(course *FormatCourseInside, client *resty.Client) ([]*Announcement, error) {
+	res, err := client.R().Get(fmt.Sprintf("https://lnt.xmu.edu.cn/api/courses/%d/bulletins", course.Id))
+	if err != nil {
+		return nil, err
+	}
+	data, err := utils.UnmarshalJSON[APICourseBulletins](res.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Announcement
+	for _, bulletin := range data.Bulletins {
+		announcement := Announcement{
+			Id:       bulletin.Id,
+			CourseId: course.Id,
+			Course:   course.Name,
+			Title:    bulletin.Title,
+			Content:  stripHTML(bulletin.Content),
+			Author:   bulletin.CreatedBy.Name,
+		}
+		announcement.CreatedAt, _ = ParseLNTTime(bulletin.CreatedAt)
+		ret = append(ret, &announcement)
+	}
+
+	SortAnnouncements(ret)
+	return ret, nil
+}
+
+func SortAnnouncements(announcements []*Announcement) {
+	sort.SliceStable(announcements, func(i, j int) bool {
+		return announcements[i].CreatedAt.After(announcements[j].CreatedAt)
+	})
+}
+
+// AnnouncementSetting 用户的公告推送设置
+type AnnouncementSetting struct {
+	Enabled bool         `json:"enabled"`
+	Target  NotifyTarget `json:"target"`
+}
+
+const announcementSettingBucket = "announcement_setting"
+const announcementSeenBucket = "announcement_seen"
+
+func GetAnnouncementSetting(uin uint32) (*AnnouncementSetting, bool) {
+	setting, ok, err := DBGet[AnnouncementSetting](announcementSettingBucket, uint32ToBytes(uin))
+	if err != nil {
+		Logger.Warning("读取公告推送设置失败 %v", err)
+		return nil, false
+	}
+	return setting, ok
+}
+
+func SetAnnouncementSetting(uin uint32, setting *AnnouncementSetting) error {
+	return DBPut(announcementSettingBucket, uint32ToBytes(uin), setting)
+}
+
+func announcementSeenKey(target NotifyTarget, courseId int, id int) []byte {
+	return []byte(fmt.Sprintf("%d-%d-%d-%d", target.GroupUin, target.Uin, courseId, id))
+}
+
+// IsAnnouncementCourseInitialized 推送目标是否已经记录过该课程的公告
+func IsAnnouncementCourseInitialized(target NotifyTarget, courseId int) bool {
+	_, ok, err := DBGet[time.Time](announcementSeenBucket, announcementSeenKey(target, courseId, 0))
+	if err != nil {
+		Logger.Warning("读取公告推送记录失败 %v", err)
+	}
+	return ok
+}
+
+func IsAnnouncementSeen(target NotifyTarget, announcement *Announcement) bool {
+	_, ok, err := DBGet[time.Time](announcementSeenBucket, announcementSeenKey(target, announcement.CourseId, announcement.Id))
+	if err != nil {
+		Logger.Warning("读取公告推送记录失败 %v", err)
+	}
+	return ok
+}
+
+// MarkAnnouncementsSeen 记录已经推送过的公告，同时标记该课程已经初始化
+func MarkAnnouncementsSeen(target NotifyTarget, courseId int, announcements []*Announcement) error {
+	now := time.Now()
+	err := DBPut(announcementSeenBucket, announcementSeenKey(target, courseId, 0), now)
+	if err != nil {
+		return err
+	}
+	for _, announcement := range announcements {
+		err := DBPut(announcementSeenBucket, announcementSeenKey(target, courseId, announcement.Id), now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
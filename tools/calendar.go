@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// CalendarEvent 日历中的一个事件
+type CalendarEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	// RRule 重复规则，为空时不重复
+	RRule string
+	// Alarms 在开始前多久提醒
+	Alarms []time.Duration
+}
+
+// Calendar 课程表，可以导出为 RFC 5545 格式
+type Calendar struct {
+	Name   string
+	Events []*CalendarEvent
+}
+
+var icsLineLimit = 75
+
+func icsEscape(text string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return replacer.Replace(text)
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func icsDuration(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return fmt.Sprintf("-PT%dH", int(d.Hours()))
+	}
+	return fmt.Sprintf("-PT%dM", int(d.Minutes()))
+}
+
+// icsFold 按照 RFC 5545 将超过75字节的行折叠，不会截断UTF-8字符
+func icsFold(line string) string {
+	var builder strings.Builder
+	length := 0
+	for _, r := range line {
+		size := len(string(r))
+		if length+size > icsLineLimit {
+			builder.WriteString("\r\n ")
+			length = 1
+		}
+		builder.WriteRune(r)
+		length += size
+	}
+	builder.WriteString("\r\n")
+	return builder.String()
+}
+
+func (c *Calendar) ToICS() string {
+	var builder strings.Builder
+	write := func(line string) {
+		builder.WriteString(icsFold(line))
+	}
+
+	now := icsTime(time.Now())
+	write("BEGIN:VCALENDAR")
+	write("VERSION:2.0")
+	write("PRODID:-//XMU-CM-Bot//Course Schedule//CN")
+	write("CALSCALE:GREGORIAN")
+	write("METHOD:PUBLISH")
+	write("X-WR-CALNAME:" + icsEscape(c.Name))
+	write("X-WR-TIMEZONE:Asia/Shanghai")
+	for _, event := range c.Events {
+		write("BEGIN:VEVENT")
+		write("UID:" + event.UID)
+		write("DTSTAMP:" + now)
+		write("DTSTART:" + icsTime(event.Start))
+		write("DTEND:" + icsTime(event.End))
+		write("SUMMARY:" + icsEscape(event.Summary))
+		if event.Description != "" {
+			write("DESCRIPTION:" + icsEscape(event.Description))
+		}
+		if event.Location != "" {
+			write("LOCATION:" + icsEscape(event.Location))
+		}
+		if event.RRule != "" {
+			write("RRULE:" + event.RRule)
+		}
+		for _, alarm := range event.Alarms {
+			write("BEGIN:VALARM")
+			write("ACTION:DISPLAY")
+			write("DESCRIPTION:" + icsEscape(event.Summary))
+			write("TRIGGER:" + icsDuration(alarm))
+			write("END:VALARM")
+		}
+		write("END:VEVENT")
+	}
+	write("END:VCALENDAR")
+	return builder.String()
+}
+
+type APIClassSchedule struct {
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+	StartWeek int    `json:"start_week"`
+	EndWeek   int    `json:"end_week"`
+	Location  string `json:"location"`
+}
+
+type APIClassSchedules struct {
+	ClassSchedules []*APIClassSchedule `json:"class_schedules"`
+}
+
+type APICourseDate struct {
+	StartDate string `json:"start_date"`
+}
+
+var lectureAlarm = 15 * time.Minute
+var deadlineAlarms = []time.Duration{24 * time.Hour, 1 * time.Hour}
+
+// weekStart 返回日期所在周的周一零点
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+func parseClock(day time.Time, clock string) (time.Time, bool) {
+	t, err := time.ParseInLocation("15:04", clock, day.Location())
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location()), true
+}
+
+// GetLectureEvents 获取课程的上课时间，按周重复
+func GetLectureEvents(course *FormatCourseInside, client *resty.Client) ([]*CalendarEvent, error) {
+	res, err := client.R().Get(fmt.Sprintf("https://lnt.xmu.edu.cn/api/courses/%d?fields=start_date", course.Id))
+	if err != nil {
+		return nil, err
+	}
+	courseDate, err := utils.UnmarshalJSON[APICourseDate](res.Body())
+	if err != nil {
+		return nil, err
+	}
+	startDate, err := time.ParseInLocation("2006-01-02", courseDate.StartDate, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err = client.R().Get(fmt.Sprintf("https://lnt.xmu.edu.cn/api/courses/%d/class-schedules", course.Id))
+	if err != nil {
+		return nil, err
+	}
+	schedules, err := utils.UnmarshalJSON[APIClassSchedules](res.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	firstWeek := weekStart(startDate)
+	var ret []*CalendarEvent
+	for i, schedule := range schedules.ClassSchedules {
+		if schedule.Weekday < 1 || schedule.Weekday > 7 || schedule.StartWeek < 1 || schedule.EndWeek < schedule.StartWeek {
+			continue
+		}
+		day := firstWeek.AddDate(0, 0, (schedule.StartWeek-1)*7+schedule.Weekday-1)
+		start, ok := parseClock(day, schedule.StartTime)
+		if !ok {
+			continue
+		}
+		end, ok := parseClock(day, schedule.EndTime)
+		if !ok {
+			continue
+		}
+		ret = append(ret, &CalendarEvent{
+			UID:      fmt.Sprintf("lecture-%d-%d@xmu-cm-bot", course.Id, i),
+			Summary:  course.Name,
+			Location: schedule.Location,
+			Start:    start,
+			End:      end,
+			RRule:    fmt.Sprintf("FREQ=WEEKLY;COUNT=%d", schedule.EndWeek-schedule.StartWeek+1),
+			Alarms:   []time.Duration{lectureAlarm},
+		})
+	}
+	return ret, nil
+}
+
+// GetTaskEvent 将作业、考试和测验转换为日历事件
+func GetTaskEvent(task *CourseTask) *CalendarEvent {
+	event := CalendarEvent{
+		UID:         fmt.Sprintf("%s-%d@xmu-cm-bot", task.Kind, task.Id),
+		Summary:     fmt.Sprintf("[%s] %s - %s", task.Kind, task.Course, task.Title),
+		Description: fmt.Sprintf("%s %s 截止: %s", task.Course, task.Title, task.EndTime.Format("2006-01-02 15:04")),
+		Start:       task.EndTime.Add(-30 * time.Minute),
+		End:         task.EndTime,
+		Alarms:      deadlineAlarms,
+	}
+	if task.Kind == CourseTaskExam && !task.StartTime.IsZero() && task.StartTime.Before(task.EndTime) {
+		event.Start = task.StartTime
+	}
+	return &event
+}
+
+// BuildSchedule 根据当前学期的课程、作业截止时间和考试时间生成课程表
+func BuildSchedule(client *resty.Client) (*Calendar, error) {
+	courseData, err := GetCourseData(client)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar := Calendar{Name: "厦门大学课程表 " + CurrentSemester()}
+	for _, course := range *GetCurrentCourseData(courseData) {
+		lectures, err := GetLectureEvents(course, client)
+		if err != nil {
+			Logger.Warning("获取上课时间失败 %s %v", course.Name, err)
+		}
+		calendar.Events = append(calendar.Events, lectures...)
+
+		tasks, err := GetCourseTasks(course, client)
+		if err != nil {
+			Logger.Warning("获取课程任务失败 %s %v", course.Name, err)
+			continue
+		}
+		for _, task := range tasks {
+			calendar.Events = append(calendar.Events, GetTaskEvent(task))
+		}
+	}
+	return &calendar, nil
+}
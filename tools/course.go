@@ -1,8 +1,11 @@
 package tools
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -93,6 +96,19 @@ func GetSemesterStr(dateStr string) (string, error) {
 	return GetSemesterInfo(t), nil
 }
 
+// ParseLNTTime 解析课程平台返回的时间，空字符串或格式错误时返回false
+func ParseLNTTime(timeStr string) (time.Time, bool) {
+	if timeStr == "" {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		Logger.Debug("时间解析失败 %s", timeStr)
+		return time.Time{}, false
+	}
+	return t.Local(), true
+}
+
 func GetSemesterInfo(t time.Time) string {
 	year := t.Year()
 	month := int(t.Month())
@@ -127,6 +143,73 @@ func GetSemesterInfo(t time.Time) string {
 	return fmt.Sprintf("%d-%d学年 %s", academicYearStart, academicYearEnd, semester)
 }
 
+var semesterTerms = []struct {
+	Name     string
+	Keywords []string
+}{
+	{Name: "第一学期", Keywords: []string{"1", "一", "上", "秋", "秋季", "第一学期"}},
+	{Name: "第二学期", Keywords: []string{"2", "二", "下", "春", "春季", "第二学期"}},
+	{Name: "第三学期", Keywords: []string{"3", "三", "小", "夏", "夏季", "第三学期"}},
+}
+
+// ParseSemesterTerm 将用户输入的学期转换为学期名称，如 "秋" 转换为 "第一学期"
+func ParseSemesterTerm(term string) (string, bool) {
+	for _, semesterTerm := range semesterTerms {
+		for _, keyword := range semesterTerm.Keywords {
+			if term == keyword || strings.TrimSuffix(term, "学期") == keyword {
+				return semesterTerm.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ParseAcademicYear 将 "2024" 或 "2024-2025" 转换为学年字符串 "2024-2025学年"
+func ParseAcademicYear(year string) (string, bool) {
+	year = strings.TrimSuffix(year, "学年")
+	parts := strings.Split(year, "-")
+	start, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		return "", false
+	}
+	if len(parts) == 2 {
+		end, err := strconv.Atoi(parts[1])
+		if err != nil || end != start+1 {
+			return "", false
+		}
+	}
+	return fmt.Sprintf("%d-%d学年", start, start+1), true
+}
+
+// SemesterOrder 返回学期的排序依据，越新的学期越大
+func SemesterOrder(semester string) int {
+	var start, end int
+	if _, err := fmt.Sscanf(semester, "%d-%d学年", &start, &end); err != nil {
+		return 0
+	}
+	for i, semesterTerm := range semesterTerms {
+		if strings.Contains(semester, semesterTerm.Name) {
+			return start*10 + i + 1
+		}
+	}
+	return start * 10
+}
+
+// FilterCourseDataBySemester 筛选学年和学期，参数为空时不筛选对应的部分
+func FilterCourseDataBySemester(courseData *FormatCourseData, academicYear, term string) *FormatCourseData {
+	var data FormatCourseData
+	for _, course := range *courseData {
+		if academicYear != "" && !strings.HasPrefix(course.Semester, academicYear) {
+			continue
+		}
+		if term != "" && !strings.Contains(course.Semester, term) {
+			continue
+		}
+		data = append(data, course)
+	}
+	return &data
+}
+
 func GetRecentCourseData(client *resty.Client) (*FormatCourseData, error) {
 	var data FormatCourseData
 	res, err := client.R().Get("https://lnt.xmu.edu.cn/api/user/recently-visited-courses")
@@ -232,17 +315,92 @@ func GetCourseById(id int, client *resty.Client) (*FormatCourseInside, error) {
 	return &data, nil
 }
 
-func GetLLMChooseCourse(courseData, recentCourseData *FormatCourseData, command string, client *resty.Client) (*FormatCourseInside, error) {
+// maxCourseCandidates 无法确定课程时最多列出的候选课程数
+var maxCourseCandidates = 5
+
+// AmbiguousCourseError 无法确定指令中是哪一门课，Candidates 为可能的课程
+type AmbiguousCourseError struct {
+	Candidates FormatCourseData
+}
+
+func (e *AmbiguousCourseError) Error() string {
+	if len(e.Candidates) == 0 {
+		return "请更加清晰阐明是哪一门课"
+	}
+	var names []string
+	for _, course := range e.Candidates {
+		names = append(names, course.Name)
+	}
+	return "请更加清晰阐明是哪一门课，可能是: " + strings.Join(names, "、")
+}
+
+// RequestError 请求课程平台失败，Message 为发送给用户的信息，Err 为原始错误
+type RequestError struct {
+	Message string
+	Err     error
+}
+
+func (e *RequestError) Error() string {
+	return e.Message
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransportError 判断是否为网络请求失败，只有这类错误重试可能成功，登录过期和返回格式错误重试也不会成功
+func IsTransportError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// courseCandidates 优先使用本地匹配的结果，没有匹配时列出最近访问和本学期的课程
+func courseCandidates(courseData, recentCourseData *FormatCourseData, command string) FormatCourseData {
+	var data FormatCourseData
+	add := func(course *FormatCourseInside) {
+		if len(data) >= maxCourseCandidates {
+			return
+		}
+		if _, ok := data.Get(course.Id); !ok {
+			data = append(data, course)
+		}
+	}
+
+	for _, candidate := range RankCourses(courseData, recentCourseData, command) {
+		add(candidate.Course)
+	}
+	if len(data) > 0 {
+		return data
+	}
+	for _, recent := range *recentCourseData {
+		if course, ok := courseData.Get(recent.Id); ok {
+			add(course)
+		}
+	}
+	for _, course := range *GetCurrentCourseData(courseData) {
+		add(course)
+	}
+	return data
+}
+
+func GetLLMChooseCourse(ctx context.Context, courseData, recentCourseData *FormatCourseData, command string, client *resty.Client) (*FormatCourseInside, error) {
 	prompt := getLLMChoosePrompt(courseData, recentCourseData, command)
-	msg := LoopGetJsonReturn[LLMCourseResponse](Llm.Choice, prompt)
+	msg, err := LoopGetJsonReturn[LLMCourseResponse](ctx, Llm.Choice, prompt)
+	var quotaErr *LLMQuotaError
+	if errors.As(err, &quotaErr) {
+		return nil, err
+	}
+	if err != nil {
+		Logger.Warning("LLM选择课程失败 %v", err)
+		return nil, errors.New("选择课程失败，请稍后重试")
+	}
 	if msg.Course == nil {
-		return nil, errors.New("请更加清晰阐明是哪一门课")
+		return nil, &AmbiguousCourseError{Candidates: courseCandidates(courseData, recentCourseData, command)}
 	}
 	courseId := *msg.Course
 	Logger.Info("获取到课程id: ", courseId)
 	course, ok := courseData.Get(courseId)
 	if !ok {
-		var err error
 		course, err = GetCourseById(courseId, client)
 		if err != nil {
 			return nil, err
@@ -252,14 +410,42 @@ func GetLLMChooseCourse(courseData, recentCourseData *FormatCourseData, command
 	return course, nil
 }
 
+// GetCommandCourse 获取用户的课程列表并根据指令选择课程，本地无法确定时再交给大模型选择
+func GetCommandCourse(ctx context.Context, command string, client *resty.Client) (*FormatCourseInside, error) {
+	courseData, err := GetCourseData(client)
+	if err != nil {
+		Logger.Warning("获取课程信息失败 %v", err)
+		return nil, &RequestError{Message: "获取课程信息失败", Err: err}
+	}
+
+	rencentCourseData, err := GetRecentCourseData(client)
+	if err != nil {
+		Logger.Warning("获取最近课程信息失败 %v", err)
+		return nil, &RequestError{Message: "获取最近课程失败", Err: err}
+	}
+
+	if course, ok := ResolveCourse(courseData, rencentCourseData, command); ok {
+		Logger.Info("本地匹配到课程: %s", course.Name)
+		return course, nil
+	}
+	return GetLLMChooseCourse(ctx, courseData, rencentCourseData, command, client)
+}
+
 type CourseActivityUpload struct {
 	Name        string `json:"name"`
 	ReferenceId int    `json:"reference_id"`
+	Size        int64  `json:"size"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
 }
 
 type CourseActivity struct {
-	Title   string                 `json:"title"`
-	Uploads []CourseActivityUpload `json:"uploads"`
+	Id        int                    `json:"id"`
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	StartTime string                 `json:"start_time"`
+	EndTime   string                 `json:"end_time"`
+	Uploads   []CourseActivityUpload `json:"uploads"`
 }
 
 type APICourseActivities struct {
@@ -269,18 +455,26 @@ type APICourseActivities struct {
 type FormatFileData = []*FormatFileInside
 
 type FormatFileInside struct {
-	Name string `json:"name"`
-	Id   int    `json:"id"`
+	Name      string    `json:"name"`
+	Id        int       `json:"id"`
+	Activity  string    `json:"activity"`
+	FileName  string    `json:"file_name"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
-func GetCourseActivities(courseId int, client *resty.Client) (*FormatFileData, error) {
-	var data FormatFileData
+func getAPICourseActivities(courseId int, client *resty.Client) (*APICourseActivities, error) {
 	res, err := client.R().Get(fmt.Sprintf("https://lnt.xmu.edu.cn/api/courses/%d/activities", courseId))
 	if err != nil {
-		return &data, err
+		return nil, err
 	}
 
-	unformatData, err := utils.UnmarshalJSON[APICourseActivities](res.Body())
+	return utils.UnmarshalJSON[APICourseActivities](res.Body())
+}
+
+func GetCourseActivities(courseId int, client *resty.Client) (*FormatFileData, error) {
+	var data FormatFileData
+	unformatData, err := getAPICourseActivities(courseId, client)
 	if err != nil {
 		return &data, err
 	}
@@ -288,7 +482,19 @@ func GetCourseActivities(courseId int, client *resty.Client) (*FormatFileData, e
 	for _, activity := range unformatData.Activities {
 		title := activity.Title
 		for _, upload := range activity.Uploads {
-			data = append(data, &FormatFileInside{Name: strings.Join([]string{title, upload.Name}, "-"), Id: upload.ReferenceId})
+			file := FormatFileInside{
+				Name:     strings.Join([]string{title, upload.Name}, "-"),
+				Id:       upload.ReferenceId,
+				Activity: title,
+				FileName: upload.Name,
+				Size:     upload.Size,
+			}
+			if updatedAt, ok := ParseLNTTime(upload.UpdatedAt); ok {
+				file.UpdatedAt = updatedAt
+			} else if createdAt, ok := ParseLNTTime(upload.CreatedAt); ok {
+				file.UpdatedAt = createdAt
+			}
+			data = append(data, &file)
 		}
 	}
 
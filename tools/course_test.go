@@ -0,0 +1,38 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestIsTransportError(t *testing.T) {
+	transport := &url.Error{Op: "Get", URL: "https://lnt.xmu.edu.cn", Err: errors.New("connection reset")}
+	var syntaxErr *json.SyntaxError
+	parseErr := json.Unmarshal([]byte("<html>"), &struct{}{})
+	if !errors.As(parseErr, &syntaxErr) {
+		t.Fatalf("期望JSON解析错误, 得到 %v", parseErr)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "没有错误"},
+		{name: "网络错误", err: transport, want: true},
+		{name: "包装的网络错误", err: &RequestError{Message: "获取文件失败", Err: fmt.Errorf("请求失败: %w", transport)}, want: true},
+		{name: "登录过期返回网页", err: &RequestError{Message: "获取课程信息失败", Err: parseErr}},
+		{name: "没有匹配的文件", err: errors.New("没有符合筛选条件的文件")},
+		{name: "课程不明确", err: &AmbiguousCourseError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransportError(tt.err); got != tt.want {
+				t.Fatalf("IsTransportError(%v) = %v, 期望 %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
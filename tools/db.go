@@ -1,8 +1,10 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -205,12 +207,146 @@ func (db *DB) DeInit() error {
 	return db.db.Close()
 }
 
+// DBPut 将数据序列化为JSON后写入指定的桶
+func DBPut(bucketName string, key []byte, value any) error {
+	return Db.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+
+		valueBytes, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(key, valueBytes)
+	})
+}
+
+// DBGet 从指定的桶中读取数据，不存在时返回false
+func DBGet[T any](bucketName string, key []byte) (*T, bool, error) {
+	var ret *T
+	err := Db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		valueBytes := bucket.Get(key)
+		if valueBytes == nil {
+			return nil
+		}
+
+		var err error
+		ret, err = utils.UnmarshalJSON[T](valueBytes)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return ret, ret != nil, nil
+}
+
+// DBDelete 删除指定桶中的数据
+func DBDelete(bucketName string, key []byte) error {
+	return Db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.Delete(key)
+	})
+}
+
+// DBDeletePrefix 删除指定桶中所有以prefix开头的数据
+func DBDeletePrefix(bucketName string, prefix []byte) error {
+	return Db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Seek(prefix) {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DBDeleteBefore 删除指定桶中所有键小于key的数据，用于清理以时间开头的键
+func DBDeleteBefore(bucketName string, key []byte) error {
+	return Db.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && bytes.Compare(k, key) < 0; k, _ = cursor.First() {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DBForEachPrefix 遍历指定桶中所有以prefix开头的数据，解析失败的数据会被跳过
+// 回调运行在只读事务中，不能在回调里写入数据库
+func DBForEachPrefix[T any](bucketName string, prefix []byte, f func(key []byte, value *T) error) error {
+	return Db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = cursor.Next() {
+			value, err := utils.UnmarshalJSON[T](v)
+			if err != nil {
+				Logger.Warning("桶 %s 中的数据解析失败 %s", bucketName, string(k))
+				continue
+			}
+			if err := f(k, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DBForEach 遍历指定桶中的所有数据，解析失败的数据会被跳过
+// 回调运行在只读事务中，不能在回调里写入数据库
+func DBForEach[T any](bucketName string, f func(key []byte, value *T) error) error {
+	return Db.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(bucketName))
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			value, err := utils.UnmarshalJSON[T](v)
+			if err != nil {
+				Logger.Warning(fmt.Sprintf("桶 %s 中的数据解析失败 %s", bucketName, string(k)))
+				return nil
+			}
+			return f(k, value)
+		})
+	})
+}
+
 func uint32ToBytes(id uint32) []byte {
 	b := make([]byte, 4)
 	binary.BigEndian.PutUint32(b, id)
 	return b
 }
 
+func bytesToUint32(b []byte) uint32 {
+	return binary.BigEndian.Uint32(b)
+}
+
 func messageEventGet[T message.GroupMessage | message.PrivateMessage | message.TempMessage](db *bolt.DB, bucketName string, task messageReadTask[T]) messageTaskReadResponse[T] {
 	var msg *T
 	err := db.View(func(tx *bolt.Tx) error {
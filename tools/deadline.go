@@ -0,0 +1,230 @@
+package tools
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+type CourseTaskKind string
+
+const (
+	CourseTaskHomework CourseTaskKind = "homework"
+	CourseTaskExam     CourseTaskKind = "exam"
+	CourseTaskQuiz     CourseTaskKind = "quiz"
+)
+
+func (k CourseTaskKind) String() string {
+	switch k {
+	case CourseTaskHomework:
+		return "作业"
+	case CourseTaskExam:
+		return "考试"
+	case CourseTaskQuiz:
+		return "测验"
+	default:
+		return string(k)
+	}
+}
+
+// CourseTask 表示一个有截止时间的课程任务，如作业、考试、测验
+type CourseTask struct {
+	Id        int            `json:"id"`
+	CourseId  int            `json:"course_id"`
+	Course    string         `json:"course"`
+	Kind      CourseTaskKind `json:"kind"`
+	Title     string         `json:"title"`
+	StartTime time.Time      `json:"start_time"`
+	EndTime   time.Time      `json:"end_time"`
+}
+
+// Key 任务的唯一标识
+func (t *CourseTask) Key() string {
+	return fmt.Sprintf("%s-%d", t.Kind, t.Id)
+}
+
+func (t *CourseTask) ToString() string {
+	return fmt.Sprintf("[%s] %s - %s 截止: %s", t.Kind, t.Course, t.Title, t.EndTime.Format("2006-01-02 15:04"))
+}
+
+type APICourseExam struct {
+	Id        int    `json:"id"`
+	Title     string `json:"title"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+type APICourseExams struct {
+	Exams []APICourseExam `json:"exams"`
+}
+
+var activityTaskKinds = map[string]CourseTaskKind{
+	"homework": CourseTaskHomework,
+	"exam":     CourseTaskExam,
+	"quiz":     CourseTaskQuiz,
+}
+
+// GetCourseTasks 获取课程中的作业、考试和测验
+func GetCourseTasks(course *FormatCourseInside, client *resty.Client) ([]*CourseTask, error) {
+	activities, err := getAPICourseActivities(course.Id, client)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []*CourseTask
+	seen := make(map[string]bool)
+	add := func(task *CourseTask, start, end string) {
+		task.StartTime, _ = ParseLNTTime(start)
+		endTime, ok := ParseLNTTime(end)
+		if !ok || seen[task.Key()] {
+			return
+		}
+		task.EndTime = endTime
+		seen[task.Key()] = true
+		data = append(data, task)
+	}
+
+	for _, activity := range activities.Activities {
+		kind, ok := activityTaskKinds[activity.Type]
+		if !ok {
+			continue
+		}
+		add(&CourseTask{Id: activity.Id, CourseId: course.Id, Course: course.Name, Kind: kind, Title: activity.Title}, activity.StartTime, activity.EndTime)
+	}
+
+	res, err := client.R().Get(fmt.Sprintf("https://lnt.xmu.edu.cn/api/courses/%d/exams", course.Id))
+	if err != nil {
+		Logger.Warning("获取课程考试失败 %v", err)
+		return data, nil
+	}
+	exams, err := utils.UnmarshalJSON[APICourseExams](res.Body())
+	if err != nil {
+		Logger.Warning("课程考试解析失败 %v", err)
+		return data, nil
+	}
+	for _, exam := range exams.Exams {
+		add(&CourseTask{Id: exam.Id, CourseId: course.Id, Course: course.Name, Kind: CourseTaskExam, Title: exam.Title}, exam.StartTime, exam.EndTime)
+	}
+
+	return data, nil
+}
+
+// GetCurrentCourseData 筛选出当前学期的课程
+func GetCurrentCourseData(courseData *FormatCourseData) *FormatCourseData {
+	semester := CurrentSemester()
+	var data FormatCourseData
+	for _, course := range *courseData {
+		if course.Semester == semester {
+			data = append(data, course)
+		}
+	}
+	return &data
+}
+
+// GetUpcomingTasks 获取当前学期所有课程中尚未截止的任务，按截止时间排序
+func GetUpcomingTasks(client *resty.Client) ([]*CourseTask, error) {
+	courseData, err := GetCourseData(client)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var data []*CourseTask
+	for _, course := range *GetCurrentCourseData(courseData) {
+		tasks, err := GetCourseTasks(course, client)
+		if err != nil {
+			Logger.Warning("获取课程任务失败 %s %v", course.Name, err)
+			continue
+		}
+		for _, task := range tasks {
+			if task.EndTime.After(now) {
+				data = append(data, task)
+			}
+		}
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].EndTime.Before(data[j].EndTime)
+	})
+	return data, nil
+}
+
+func CourseTaskListString(tasks []*CourseTask) string {
+	var data []string
+	for i, task := range tasks {
+		data = append(data, fmt.Sprintf("%d. %s", i+1, task.ToString()))
+	}
+	return strings.Join(data, "\n")
+}
+
+// DeadlineReminder 一条截止时间提醒
+type DeadlineReminder struct {
+	Uin      uint32     `json:"uin"`
+	Task     CourseTask `json:"task"`
+	RemindAt time.Time  `json:"remind_at"`
+	Before   string     `json:"before"`
+	Sent     bool       `json:"sent"`
+}
+
+// DeadlineSetting 用户的截止提醒设置，默认开启
+type DeadlineSetting struct {
+	Disabled bool `json:"disabled"`
+}
+
+const deadlineReminderBucket = "deadline_reminder"
+const deadlineSettingBucket = "deadline_setting"
+
+func deadlineReminderKey(uin uint32, task *CourseTask, before string) []byte {
+	return []byte(fmt.Sprintf("%d-%s-%s", uin, task.Key(), before))
+}
+
+func GetDeadlineSetting(uin uint32) *DeadlineSetting {
+	setting, ok, err := DBGet[DeadlineSetting](deadlineSettingBucket, uint32ToBytes(uin))
+	if err != nil {
+		Logger.Warning("读取截止提醒设置失败 %v", err)
+	}
+	if !ok {
+		return &DeadlineSetting{}
+	}
+	return setting
+}
+
+func SetDeadlineSetting(uin uint32, setting *DeadlineSetting) error {
+	return DBPut(deadlineSettingBucket, uint32ToBytes(uin), setting)
+}
+
+func GetDeadlineReminder(uin uint32, task *CourseTask, before string) (*DeadlineReminder, bool) {
+	reminder, ok, err := DBGet[DeadlineReminder](deadlineReminderBucket, deadlineReminderKey(uin, task, before))
+	if err != nil {
+		Logger.Warning("读取截止提醒失败 %v", err)
+		return nil, false
+	}
+	return reminder, ok
+}
+
+func PutDeadlineReminder(reminder *DeadlineReminder) error {
+	return DBPut(deadlineReminderBucket, deadlineReminderKey(reminder.Uin, &reminder.Task, reminder.Before), reminder)
+}
+
+func DeleteDeadlineReminder(reminder *DeadlineReminder) error {
+	return DBDelete(deadlineReminderBucket, deadlineReminderKey(reminder.Uin, &reminder.Task, reminder.Before))
+}
+
+// DeleteUserDeadlineReminders 删除用户所有的截止提醒，用于退出登录
+func DeleteUserDeadlineReminders(uin uint32) error {
+	return DBDeletePrefix(deadlineReminderBucket, []byte(fmt.Sprintf("%d-", uin)))
+}
+
+// GetAllDeadlineReminders 获取所有保存的截止提醒
+func GetAllDeadlineReminders() ([]*DeadlineReminder, error) {
+	var data []*DeadlineReminder
+	err := DBForEach(deadlineReminderBucket, func(key []byte, value *DeadlineReminder) error {
+		data = append(data, value)
+		return nil
+	})
+	return data, err
+}
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/config"
+)
+
+// FileCacheEntry 文件缓存的索引，通过文件内容的sha256找到缓存的文件
+type FileCacheEntry struct {
+	RefId    int       `json:"ref_id"`
+	Size     int64     `json:"size"`
+	ETag     string    `json:"etag"`
+	Hash     string    `json:"hash"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// FileCacheStruct 按内容寻址的本地文件缓存，文件保存在 CachePath/files/<sha256>
+type FileCacheStruct struct {
+	dir     string
+	maxSize int64
+	// mu 保护 pinned、linked 以及缓存文件的创建和删除
+	mu     sync.Mutex
+	pinned map[string]int
+	// linked 已经发出下载链接的文件，在链接过期前不会被淘汰
+	linked map[string]time.Time
+}
+
+const fileCacheBucket = "file_cache"
+
+var FileCache *FileCacheStruct
+
+func FileCacheInit(c *config.Config) error {
+	dir := filepath.Join(c.Bot.CachePath, "files")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+	FileCache = &FileCacheStruct{
+		dir:     dir,
+		maxSize: c.Download.GetFileCacheSize(),
+		pinned:  make(map[string]int),
+		linked:  make(map[string]time.Time),
+	}
+	return nil
+}
+
+func fileCacheKey(refId int, size int64) []byte {
+	return []byte(fmt.Sprintf("%d-%d", refId, size))
+}
+
+func (c *FileCacheStruct) path(hash string) string {
+	return filepath.Join(c.dir, hash)
+}
+
+// pin 标记缓存文件正在使用，使用中的文件不会被淘汰，文件不存在时返回false
+func (c *FileCacheStruct) pin(hash string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := os.Stat(c.path(hash)); err != nil {
+		return false
+	}
+	c.pinned[hash]++
+	return true
+}
+
+func (c *FileCacheStruct) release(hash string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			c.pinned[hash]--
+			if c.pinned[hash] <= 0 {
+				delete(c.pinned, hash)
+			}
+		})
+	}
+}
+
+// KeepUntil 在 until 之前不淘汰文件，用于已经发出的下载链接
+func (c *FileCacheStruct) KeepUntil(hash string, until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if until.After(c.linked[hash]) {
+		c.linked[hash] = until
+	}
+}
+
+// Open 通过内容哈希获取缓存文件的路径，使用完毕后需要调用返回的函数释放文件
+func (c *FileCacheStruct) Open(hash string) (string, func(), bool) {
+	if !c.pin(hash) {
+		return "", nil, false
+	}
+	return c.path(hash), c.release(hash), true
+}
+
+func (c *FileCacheStruct) hit(key []byte, entry *FileCacheEntry) (string, func(), bool) {
+	if !c.pin(entry.Hash) {
+		return "", nil, false
+	}
+	entry.LastUsed = time.Now()
+	if err := DBPut(fileCacheBucket, key, entry); err != nil {
+		Logger.Warning("更新文件缓存失败 %v", err)
+	}
+	return c.path(entry.Hash), c.release(entry.Hash), true
+}
+
+// Fetch 获取课程文件的本地路径，缓存中没有时下载，使用完毕后需要调用返回的函数释放文件
+func (c *FileCacheStruct) Fetch(file *FormatFileInside, client *resty.Client) (string, func(), error) {
+	return c.FetchWithProgress(file, client, nil)
+}
+
+// progressWriter 将写入的字节数报告给回调
+type progressWriter func(n int64)
+
+func (w progressWriter) Write(p []byte) (int, error) {
+	w(int64(len(p)))
+	return len(p), nil
+}
+
+// FetchWithProgress 与 Fetch 相同，下载时每读取一段数据都会调用 onRead 报告读取的字节数
+func (c *FileCacheStruct) FetchWithProgress(file *FormatFileInside, client *resty.Client, onRead func(n int64)) (string, func(), error) {
+	key := fileCacheKey(file.Id, file.Size)
+	entry, ok, err := DBGet[FileCacheEntry](fileCacheBucket, key)
+	if err != nil {
+		Logger.Warning("读取文件缓存失败 %v", err)
+	}
+	// 大小未知时通过ETag确认文件没有变化
+	if ok && file.Size > 0 {
+		if path, release, ok := c.hit(key, entry); ok {
+			return path, release, nil
+		}
+	}
+
+	url, err := GetURLById(file, client)
+	if err != nil {
+		return "", nil, err
+	}
+	req := client.R().SetDoNotParseResponse(true)
+	if ok && entry.ETag != "" {
+		req.SetHeader("If-None-Match", entry.ETag)
+	}
+	resp, err := req.Get(url)
+	if err != nil {
+		return "", nil, err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	if resp.StatusCode() == http.StatusNotModified && ok {
+		if path, release, ok := c.hit(key, entry); ok {
+			return path, release, nil
+		}
+		return "", nil, fmt.Errorf("缓存文件已被删除 %s", file.Name)
+	}
+	if resp.IsError() {
+		return "", nil, fmt.Errorf("下载失败，状态码 %d", resp.StatusCode())
+	}
+
+	tempFile, err := os.CreateTemp(c.dir, "download-*")
+	if err != nil {
+		return "", nil, err
+	}
+	hash := sha256.New()
+	writers := []io.Writer{tempFile, hash}
+	if onRead != nil {
+		writers = append(writers, progressWriter(onRead))
+	}
+	size, err := io.Copy(io.MultiWriter(writers...), body)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return "", nil, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	c.mu.Lock()
+	if _, err := os.Stat(c.path(sum)); err == nil {
+		os.Remove(tempFile.Name())
+	} else if err := os.Rename(tempFile.Name(), c.path(sum)); err != nil {
+		c.mu.Unlock()
+		os.Remove(tempFile.Name())
+		return "", nil, err
+	}
+	c.pinned[sum]++
+	c.mu.Unlock()
+
+	entry = &FileCacheEntry{
+		RefId:    file.Id,
+		Size:     size,
+		ETag:     resp.Header().Get("ETag"),
+		Hash:     sum,
+		LastUsed: time.Now(),
+	}
+	if err := DBPut(fileCacheBucket, key, entry); err != nil {
+		Logger.Warning("写入文件缓存失败 %v", err)
+	}
+
+	c.evict()
+	return c.path(sum), c.release(sum), nil
+}
+
+type fileCacheBlob struct {
+	hash     string
+	size     int64
+	lastUsed time.Time
+	keys     [][]byte
+}
+
+// evict 缓存超过大小上限时，按照最近使用时间淘汰未在使用的文件
+func (c *FileCacheStruct) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for hash, until := range c.linked {
+		if now.After(until) {
+			delete(c.linked, hash)
+		}
+	}
+
+	blobs := make(map[string]*fileCacheBlob)
+	err := DBForEach(fileCacheBucket, func(key []byte, value *FileCacheEntry) error {
+		blob, ok := blobs[value.Hash]
+		if !ok {
+			blob = &fileCacheBlob{hash: value.Hash, size: value.Size}
+			blobs[value.Hash] = blob
+		}
+		if value.LastUsed.After(blob.lastUsed) {
+			blob.lastUsed = value.LastUsed
+		}
+		blob.keys = append(blob.keys, append([]byte(nil), key...))
+		return nil
+	})
+	if err != nil {
+		Logger.Warning("读取文件缓存失败 %v", err)
+		return
+	}
+
+	var total int64
+	var data []*fileCacheBlob
+	for _, blob := range blobs {
+		total += blob.size
+		data = append(data, blob)
+	}
+	if total <= c.maxSize {
+		return
+	}
+
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].lastUsed.Before(data[j].lastUsed)
+	})
+	for _, blob := range data {
+		if total <= c.maxSize {
+			break
+		}
+		if _, ok := c.linked[blob.hash]; ok || c.pinned[blob.hash] > 0 {
+			continue
+		}
+		if err := os.Remove(c.path(blob.hash)); err != nil && !os.IsNotExist(err) {
+			Logger.Warning("删除缓存文件失败 %v", err)
+			continue
+		}
+		for _, key := range blob.keys {
+			if err := DBDelete(fileCacheBucket, key); err != nil {
+				Logger.Warning("删除文件缓存失败 %v", err)
+			}
+		}
+		total -= blob.size
+	}
+}
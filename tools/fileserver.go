@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/config"
+)
+
+// FileServerStruct 内置的文件服务器，通过带签名和有效期的链接提供缓存中的文件
+type FileServerStruct struct {
+	server  *http.Server
+	baseURL string
+	ttl     time.Duration
+	secret  []byte
+}
+
+// FileServer 未开启文件服务器时为nil
+var FileServer *FileServerStruct
+
+var fileHashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+func FileServerInit(c *config.Config) error {
+	if !c.FileServer.Enabled {
+		return nil
+	}
+	if c.FileServer.Listen == "" || c.FileServer.BaseURL == "" {
+		return errors.New("文件服务器需要配置 listen 和 baseURL")
+	}
+
+	secret := []byte(c.FileServer.Secret)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return err
+		}
+	}
+
+	fileServer := &FileServerStruct{
+		baseURL: strings.TrimSuffix(c.FileServer.BaseURL, "/"),
+		ttl:     time.Duration(c.FileServer.GetTTL()) * time.Minute,
+		secret:  secret,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/f/", fileServer.serveFile)
+	fileServer.server = &http.Server{
+		Addr:              c.FileServer.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := fileServer.server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			Logger.Error("文件服务器运行失败 %v", err)
+		}
+	}()
+	FileServer = fileServer
+	return nil
+}
+
+func (s *FileServerStruct) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func (s *FileServerStruct) sign(hash string, expire int64, filename string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(fmt.Sprintf("%s\n%d\n%s", hash, expire, filename)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// Link 生成缓存文件的下载链接，链接格式为 /f/<过期时间>/<签名>/<哈希>/<文件名>
+func (s *FileServerStruct) Link(hash, filename string) string {
+	expire := time.Now().Add(s.ttl).Unix()
+	return fmt.Sprintf("%s/f/%d/%s/%s/%s", s.baseURL, expire, s.sign(hash, expire, filename), hash, url.PathEscape(filename))
+}
+
+// TTL 下载链接的有效期
+func (s *FileServerStruct) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *FileServerStruct) serveFile(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/f/"), "/", 4)
+	if len(parts) != 4 {
+		http.NotFound(w, r)
+		return
+	}
+	expire, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	sign, hash, filename := parts[1], parts[2], parts[3]
+	if !fileHashPattern.MatchString(hash) || !hmac.Equal([]byte(sign), []byte(s.sign(hash, expire, filename))) {
+		http.Error(w, "链接无效", http.StatusForbidden)
+		return
+	}
+	if time.Now().Unix() > expire {
+		http.Error(w, "链接已过期", http.StatusGone)
+		return
+	}
+
+	path, release, ok := FileCache.Open(hash)
+	if !ok {
+		http.Error(w, "文件已被清理，请重新获取", http.StatusGone)
+		return
+	}
+	defer release()
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "文件读取失败", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		http.Error(w, "文件读取失败", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	http.ServeContent(w, r, filename, stat.ModTime(), file)
+}
+
+// FallbackURL 上传失败时提供给用户的下载链接，开启文件服务器时为签名链接，否则为课程平台的原始链接
+func FallbackURL(file *FormatFileInside, client *resty.Client) (string, error) {
+	if FileServer != nil && FileCache != nil {
+		path, release, err := FileCache.Fetch(file, client)
+		if err == nil {
+			defer release()
+			hash := filepath.Base(path)
+			link := FileServer.Link(hash, file.Name)
+			// 链接过期前保留文件，避免用户打开链接时文件已被淘汰
+			FileCache.KeepUntil(hash, time.Now().Add(FileServer.TTL()))
+			return link, nil
+		}
+		Logger.Warning("生成下载链接失败 %v", err)
+	}
+	return GetURLById(file, client)
+}
@@ -0,0 +1,224 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// FileFilter 下载文件的筛选条件
+type FileFilter struct {
+	// Extensions 文件扩展名，如 pdf、pptx，ppt 同时匹配 pptx
+	Extensions []string `json:"extensions"`
+	// Keywords 活动标题或文件名需要包含的关键词
+	Keywords []string `json:"keywords"`
+	// Latest 只保留最新的N个文件，0为不限制
+	Latest int `json:"latest"`
+	// From 和 To 为 YYYY-MM-DD 格式的日期范围，包含边界
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func (f *FileFilter) IsEmpty() bool {
+	return len(f.Extensions) == 0 && len(f.Keywords) == 0 && f.Latest == 0 && f.From == "" && f.To == ""
+}
+
+func (f *FileFilter) ToString() string {
+	var data []string
+	if len(f.Extensions) > 0 {
+		data = append(data, "类型: "+strings.Join(f.Extensions, ","))
+	}
+	if len(f.Keywords) > 0 {
+		data = append(data, "关键词: "+strings.Join(f.Keywords, ","))
+	}
+	if f.From != "" || f.To != "" {
+		data = append(data, fmt.Sprintf("日期: %s ~ %s", f.From, f.To))
+	}
+	if f.Latest > 0 {
+		data = append(data, fmt.Sprintf("最新 %d 个", f.Latest))
+	}
+	return strings.Join(data, " ")
+}
+
+func (f *FileFilter) matchExtension(file *FormatFileInside) bool {
+	if len(f.Extensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(utils.GetExtByFilepath(file.FileName))
+	for _, want := range f.Extensions {
+		want = strings.ToLower(strings.TrimPrefix(want, "."))
+		if ext == want || ext == want+"x" {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *FileFilter) matchKeywords(file *FormatFileInside) bool {
+	name := strings.ToLower(file.Name)
+	for _, keyword := range f.Keywords {
+		if !strings.Contains(name, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseFilterDate(date string, endOfDay bool) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return t, fmt.Errorf("日期格式错误，请使用 YYYY-MM-DD: %s", date)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// Validate 检查日期格式
+func (f *FileFilter) Validate() error {
+	if f.From != "" {
+		if _, err := parseFilterDate(f.From, false); err != nil {
+			return err
+		}
+	}
+	if f.To != "" {
+		if _, err := parseFilterDate(f.To, true); err != nil {
+			return err
+		}
+	}
+	if f.Latest < 0 {
+		return errors.New("最新文件数量不能为负数")
+	}
+	return nil
+}
+
+// Apply 按照筛选条件过滤文件，日期格式错误的条件会被忽略
+func (f *FileFilter) Apply(files FormatFileData) FormatFileData {
+	from, fromErr := parseFilterDate(f.From, false)
+	to, toErr := parseFilterDate(f.To, true)
+
+	var data FormatFileData
+	for _, file := range files {
+		if !f.matchExtension(file) || !f.matchKeywords(file) {
+			continue
+		}
+		if f.From != "" && fromErr == nil && file.UpdatedAt.Before(from) {
+			continue
+		}
+		if f.To != "" && toErr == nil && file.UpdatedAt.After(to) {
+			continue
+		}
+		data = append(data, file)
+	}
+
+	if f.Latest > 0 && len(data) > f.Latest {
+		sort.SliceStable(data, func(i, j int) bool {
+			return data[i].UpdatedAt.After(data[j].UpdatedAt)
+		})
+		data = data[:f.Latest]
+	}
+	return data
+}
+
+var fileFilterFlags = map[string]bool{"--ext": true, "--activity": true, "--latest": true, "--from": true, "--to": true}
+
+func splitFilterValue(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '，'
+	})
+}
+
+// ParseFileFilterFlags 从指令中解析筛选参数，返回筛选条件和去掉筛选参数后的指令
+func ParseFileFilterFlags(command string) (*FileFilter, string, error) {
+	var filter FileFilter
+	var rest []string
+	fields := strings.Fields(command)
+	for i := 0; i < len(fields); i++ {
+		flag := fields[i]
+		if !fileFilterFlags[flag] {
+			rest = append(rest, flag)
+			continue
+		}
+		if i+1 >= len(fields) {
+			return nil, command, fmt.Errorf("参数 %s 缺少值", flag)
+		}
+		i++
+		value := fields[i]
+		switch flag {
+		case "--ext":
+			filter.Extensions = append(filter.Extensions, splitFilterValue(value)...)
+		case "--activity":
+			filter.Keywords = append(filter.Keywords, splitFilterValue(value)...)
+		case "--latest":
+			latest, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, command, fmt.Errorf("参数 --latest 需要数字: %s", value)
+			}
+			filter.Latest = latest
+		case "--from":
+			filter.From = value
+		case "--to":
+			filter.To = value
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, command, err
+	}
+	return &filter, strings.Join(rest, " "), nil
+}
+
+var fileFilterHintPattern = regexp.MustCompile(`(?i)(只要|只下|仅|最新|最近|之前|之后|以前|以后|第.+[章节周讲]|ppt|pdf|doc|word|excel|xls|\d+月|\d{4}-\d{2})`)
+
+// HasFileFilterHint 指令中是否可能包含自然语言描述的筛选条件，课件、实验等词常出现在课程名和活动标题中，不作为筛选条件
+func HasFileFilterHint(command string) bool {
+	return fileFilterHintPattern.MatchString(command)
+}
+
+func getLLMFileFilterPrompt(command string) string {
+	date := time.Now().Format("2006-01-02")
+	data := []string{`你是一个专业的理解用户需求的客服，请根据用户下载课程文件的需求字符串提取文件的筛选条件并且按照要求返回JSON
+===
+# 返回的要求
+一定要符合这个格式：{"extensions":[str],"keywords":[str],"latest":int,"from":str,"to":str}
+## 参数的解释
+extensions: 文件扩展名，不带点，如 "pdf"、"ppt"，只有用户明确提到文件格式时才填写，只说课件、资料时为 []
+keywords: 活动标题或文件名需要包含的关键词，如 "第三章"，不要包含课程名称，没有要求时为 []
+latest: 只要最新的几个文件，没有要求时为 0
+from: 开始日期，格式为 YYYY-MM-DD，没有要求时为 ""
+to: 结束日期，格式为 YYYY-MM-DD，没有要求时为 ""
+## 示例 - 只要第三章的PPT
+{"extensions":["ppt"],"keywords":["第三章"],"latest":0,"from":"","to":""}
+## 示例 - 没有筛选条件
+{"extensions":[],"keywords":[],"latest":0,"from":"","to":""}
+## 注意事项
+1.  除了回复使用的工具之外，不要使用任何其他文字进行修饰，保证输出的全部为 JSON ！！！
+2.  一定要按照要求返回指定的格式，请严格遵照要求！！！
+===
+# 当前日期
+`, date, `
+===
+用户的请求：`, command,
+	}
+	return strings.Join(data, "")
+}
+
+// GetLLMFileFilter 使用大模型从自然语言中提取筛选条件
+func GetLLMFileFilter(ctx context.Context, command string) (*FileFilter, error) {
+	filter, err := LoopGetJsonReturn[FileFilter](ctx, Llm.Choice, getLLMFileFilterPrompt(command))
+	if err != nil {
+		Logger.Warning("提取筛选条件失败 %v", err)
+		return nil, errors.New("提取筛选条件失败")
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
@@ -0,0 +1,26 @@
+package tools
+
+import "testing"
+
+func TestHasFileFilterHint(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{command: "/download 高数", want: false},
+		{command: "/download 物理实验", want: false},
+		{command: "/download 高数 课件", want: false},
+		{command: "/download 大学物理实验 课件", want: false},
+		{command: "/download 高数 只要第三章的PPT", want: true},
+		{command: "/download 高数 pdf", want: true},
+		{command: "/download 高数 最新的", want: true},
+		{command: "/download 高数 10月以后", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			if got := HasFileFilterHint(tt.command); got != tt.want {
+				t.Fatalf("HasFileFilterHint(%q) = %v, 期望 %v", tt.command, got, tt.want)
+			}
+		})
+	}
+}
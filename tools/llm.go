@@ -3,12 +3,13 @@ package tools
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"time"
 
-	"github.com/openai/openai-go/v2"
-	"github.com/openai/openai-go/v2/option"
 	"github.com/vintcessun/XMU-CM-Bot/config"
 	"github.com/vintcessun/XMU-CM-Bot/utils"
 )
@@ -16,29 +17,22 @@ import (
 var retryTimes = 10
 var Llm LLM
 
-type LLMStruct struct {
-	Client openai.Client
-	Model  string
-}
-
 type LLM struct {
-	Text    *LLMStruct
-	Dynamic *LLMStruct
-	Choice  *LLMStruct
+	Text    LLMProvider
+	Dynamic LLMProvider
+	Choice  LLMProvider
 }
 
-func GetLLMFromData(data *config.LLMData) *LLMStruct {
-	return &LLMStruct{
-		Client: openai.NewClient(option.WithAPIKey(data.APIKey), option.WithBaseURL(data.BaseUrl)),
-		Model:  data.Model,
-	}
+func GetLLMFromData(data *config.LLMData) LLMProvider {
+	return NewOpenAIProvider(data.BaseUrl, data.APIKey, data.Model)
 }
 
 func LLMInit(c *config.Config) error {
+	llmQuota = c.LLM.Quota
 	Llm = LLM{
-		Text:    GetLLMFromData(&c.LLM.Text),
-		Dynamic: GetLLMFromData(&c.LLM.Dynamic),
-		Choice:  GetLLMFromData(&c.LLM.Choice),
+		Text:    newMeteredProvider("Text", GetLLMFromData(&c.LLM.Text)),
+		Dynamic: newMeteredProvider("Dynamic", GetLLMFromData(&c.LLM.Dynamic)),
+		Choice:  newMeteredProvider("Choice", GetLLMFromData(&c.LLM.Choice)),
 	}
 	return nil
 }
@@ -49,160 +43,197 @@ func RemoveThinkTags(text string) string {
 	return thinkTagPattern.ReplaceAllString(text, "")
 }
 
-func GetJsonReturn[T any](llm *LLMStruct, data string) (*T, error) {
-	var ret *T
+// jsonRepairTimes 大模型输出格式不正确时，把错误反馈给它重新生成的最大次数
+var jsonRepairTimes = 3
+
+// extractJson 去掉思考标签和代码块标记，保留JSON本体
+func extractJson(content string) string {
+	content = RemoveThinkTags(content)
+	content = strings.ReplaceAll(content, "```json", "")
+	content = strings.ReplaceAll(content, "```", "")
+	return strings.TrimSpace(content)
+}
+
+// parseJsonReturn 按照schema校验大模型的输出，并反序列化为T
+func parseJsonReturn[T any](schema *JSONSchema, content string) (*T, error) {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return nil, fmt.Errorf("不是合法的JSON: %w", err)
+	}
+	if problems := schema.Validate(value); len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "\n"))
+	}
+	return utils.UnmarshalJSON[T]([]byte(content))
+}
+
+// ParseToolArguments 按照T的schema校验并解析工具调用的参数
+func ParseToolArguments[T any](arguments string) (*T, error) {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	return parseJsonReturn[T](SchemaOf[T](), arguments)
+}
+
+func jsonSchemaPrompt(schema *JSONSchema) string {
+	return "只回复一个JSON，不要包含其他文字，JSON需要符合以下 JSON Schema:\n" + schema.String()
+}
 
-	chatCompletion, err := llm.Client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.UserMessage(data),
-		},
-		Model: llm.Model,
+// GetJsonReturn 请求一次大模型，返回按照T的schema校验后的结果，ctx 中记录调用者用于统计用量
+func GetJsonReturn[T any](ctx context.Context, llm LLMProvider, data string) (*T, error) {
+	schema := SchemaOf[T]()
+	reply, err := llm.Chat(ctx, []LLMMessage{
+		{Role: LLMRoleSystem, Content: jsonSchemaPrompt(schema)},
+		{Role: LLMRoleUser, Content: data},
 	})
 	if err != nil {
 		Logger.Warning("获得LLM返回失败")
-		return ret, err
+		return nil, err
 	}
 
-	ret_json := chatCompletion.Choices[0].Message.Content
-
-	ret_json = RemoveThinkTags(ret_json)
-	ret_json = strings.ReplaceAll(ret_json, "```json", "")
-	ret_json = strings.ReplaceAll(ret_json, "```", "")
-
-	ret, err = utils.UnmarshalJSON[T]([]byte(ret_json))
+	content := extractJson(reply.Content)
+	ret, err := parseJsonReturn[T](schema, content)
 	if err != nil {
 		Logger.Warning("LLM返回格式不正确")
-		Logger.Info("LLM返回: %s", ret_json)
-		return ret, err
+		Logger.Info("LLM返回: %s", content)
+		return nil, &LLMOutputError{Model: llm.ModelName(), Attempts: 1, Output: content, Err: err}
 	}
-
 	return ret, nil
 }
 
-func LoopGetJsonReturn[T any](llm *LLMStruct, data string) *T {
-	var ret *T
-	var err error
+// LoopGetJsonReturn 请求大模型并按照T的schema校验，格式不正确时把错误作为下一轮对话反馈给大模型修正，
+// 请求失败时重试，超过次数后返回 *LLMOutputError 或最后一次请求的错误
+func LoopGetJsonReturn[T any](ctx context.Context, llm LLMProvider, data string) (*T, error) {
+	schema := SchemaOf[T]()
+	messages := []LLMMessage{
+		{Role: LLMRoleSystem, Content: jsonSchemaPrompt(schema)},
+		{Role: LLMRoleUser, Content: data},
+	}
 
+	var err error
+	var content string
+	attempts := 0
 	for range retryTimes {
-		ret, err = GetJsonReturn[T](llm, data)
+		var reply *LLMReply
+		reply, err = llm.Chat(ctx, messages)
+		var quotaErr *LLMQuotaError
+		if errors.As(err, &quotaErr) {
+			return nil, err
+		}
+		if err != nil {
+			Logger.Warning("LLM请求失败 %v", err)
+			time.Sleep(1 * time.Second)
+			continue
+		}
+
+		attempts++
+		content = extractJson(reply.Content)
+		var ret *T
+		ret, err = parseJsonReturn[T](schema, content)
 		if err == nil {
+			return ret, nil
+		}
+		Logger.Warning("LLM返回格式不正确 %v", err)
+		Logger.Info("LLM返回: %s", content)
+		if attempts > jsonRepairTimes {
 			break
 		}
-		Logger.Warning("LLM请求失败", err)
-		time.Sleep(1 * time.Second)
+		messages = append(messages,
+			LLMMessage{Role: LLMRoleAssistant, Content: reply.Content},
+			LLMMessage{Role: LLMRoleUser, Content: "你的回复格式不正确:\n" + err.Error() + "\n请修正后重新回复，" + jsonSchemaPrompt(schema)},
+		)
 	}
 
-	return ret
+	if attempts == 0 {
+		return nil, err
+	}
+	return nil, &LLMOutputError{Model: llm.ModelName(), Attempts: attempts, Output: content, Err: err}
 }
 
-func DescribeImageByte(image []byte, extraData string) (string, error) {
+// DescribeImageByte 描述图片，ctx 中记录调用者用于统计用量和限制额度
+func DescribeImageByte(ctx context.Context, image []byte, extraData string) (string, error) {
 	base64Str := base64.StdEncoding.EncodeToString(image)
 	imageUrl := "data:image/jpeg;base64," + base64Str
 
 	var ret string
-	chatCompletion, err := Llm.Dynamic.Client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("请用具体清晰的语言描述出这张图片除了文字之外的其他东西的情况和状况"),
-			openai.UserMessage("可以参考用户的一些特别要求: " + extraData),
-			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
-				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: imageUrl, Detail: "auto"}),
-			}),
-		},
-		Model: Llm.Dynamic.Model,
-	})
+	reply, err := Llm.Dynamic.Vision(ctx, "请用具体清晰的语言描述出这张图片除了文字之外的其他东西的情况和状况", "可以参考用户的一些特别要求: "+extraData, imageUrl)
 	if err != nil {
 		Logger.Warning("获得LLM返回失败")
 		return ret, err
 	}
 
-	ret = chatCompletion.Choices[0].Message.Content
+	ret = reply.Content
 	return ret, nil
 }
 
-func LoopDescribeImageByte(image []byte, extraData string) string {
+func LoopDescribeImageByte(ctx context.Context, image []byte, extraData string) string {
 	var ret string
 	var err error
 
 	for range retryTimes {
-		ret, err = DescribeImageByte(image, extraData)
-		if err == nil {
+		ret, err = DescribeImageByte(ctx, image, extraData)
+		var quotaErr *LLMQuotaError
+		if err == nil || errors.As(err, &quotaErr) {
 			break
 		}
-		Logger.Warning("LLM请求失败", err)
+		Logger.Warning("LLM请求失败 %v", err)
 		time.Sleep(1 * time.Second)
 	}
 
 	return ret
 }
 
-func DescribeImage(imageUrl string, extraData string) (string, error) {
+func DescribeImage(ctx context.Context, imageUrl string, extraData string) (string, error) {
 	var ret string
-	chatCompletion, err := Llm.Dynamic.Client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("请用具体清晰的语言描述出这张图片除了文字之外的其他东西的情况和状况"),
-			openai.UserMessage("可以参考用户的一些特别要求: " + extraData),
-			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
-				openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: imageUrl, Detail: "auto"}),
-			}),
-		},
-		Model: Llm.Dynamic.Model,
-	})
+	reply, err := Llm.Dynamic.Vision(ctx, "请用具体清晰的语言描述出这张图片除了文字之外的其他东西的情况和状况", "可以参考用户的一些特别要求: "+extraData, imageUrl)
 	if err != nil {
 		Logger.Warning("获得LLM返回失败")
 		return ret, err
 	}
 
-	ret = chatCompletion.Choices[0].Message.Content
+	ret = reply.Content
 	return ret, nil
 }
 
-func LoopDescribeImage(imageUrl string, extraData string) string {
+func LoopDescribeImage(ctx context.Context, imageUrl string, extraData string) string {
 	var ret string
 	var err error
 
 	for range retryTimes {
-		ret, err = DescribeImage(imageUrl, extraData)
-		if err == nil {
+		ret, err = DescribeImage(ctx, imageUrl, extraData)
+		var quotaErr *LLMQuotaError
+		if err == nil || errors.As(err, &quotaErr) {
 			break
 		}
-		Logger.Warning("LLM请求失败", err)
+		Logger.Warning("LLM请求失败 %v", err)
 		time.Sleep(1 * time.Second)
 	}
 
 	return ret
 }
 
-func DescribeVoice(data []byte, format string, extraData string) (string, error) {
+func DescribeVoice(ctx context.Context, data []byte, format string, extraData string) (string, error) {
 	var ret string
-	chatCompletion, err := Llm.Dynamic.Client.Chat.Completions.New(context.TODO(), openai.ChatCompletionNewParams{
-		Messages: []openai.ChatCompletionMessageParamUnion{
-			openai.SystemMessage("请用具体清晰的语言描述出这段语音除了文字之外的其他东西的情况和状况"),
-			openai.UserMessage("可以参考用户的一些特别要求: " + extraData),
-			openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
-				openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{Data: base64.StdEncoding.EncodeToString(data), Format: format}),
-			}),
-		},
-		Model: Llm.Dynamic.Model,
-	})
+	reply, err := Llm.Dynamic.Audio(ctx, "请用具体清晰的语言描述出这段语音除了文字之外的其他东西的情况和状况", "可以参考用户的一些特别要求: "+extraData, data, format)
 	if err != nil {
 		Logger.Warning("获得LLM返回失败")
 		return ret, err
 	}
 
-	ret = chatCompletion.Choices[0].Message.Content
+	ret = reply.Content
 	return ret, nil
 }
 
-func LoopDescribeVoice(data []byte, format string, extraData string) string {
+func LoopDescribeVoice(ctx context.Context, data []byte, format string, extraData string) string {
 	var ret string
 	var err error
 
 	for range retryTimes {
-		ret, err = DescribeVoice(data, format, extraData)
-		if err == nil {
+		ret, err = DescribeVoice(ctx, data, format, extraData)
+		var quotaErr *LLMQuotaError
+		if err == nil || errors.As(err, &quotaErr) {
 			break
 		}
-		Logger.Warning("LLM请求失败", err)
+		Logger.Warning("LLM请求失败 %v", err)
 		time.Sleep(1 * time.Second)
 	}
 
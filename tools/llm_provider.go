@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/openai/openai-go/v2"
+	"github.com/openai/openai-go/v2/option"
+)
+
+const (
+	LLMRoleSystem    = "system"
+	LLMRoleUser      = "user"
+	LLMRoleAssistant = "assistant"
+	LLMRoleTool      = "tool"
+)
+
+// LLMMessage 对话中的一条消息
+type LLMMessage struct {
+	Role    string
+	Content string
+	// ToolCalls 助手消息中请求调用的工具
+	ToolCalls []LLMToolCall
+	// ToolCallId 工具消息对应的调用
+	ToolCallId string
+}
+
+// LLMTool 可以让大模型调用的工具，Parameters 为参数的 JSON Schema
+type LLMTool struct {
+	Name        string
+	Description string
+	Parameters  *JSONSchema
+}
+
+// LLMToolCall 大模型请求的一次工具调用，Arguments 为JSON格式的参数
+type LLMToolCall struct {
+	Id        string
+	Name      string
+	Arguments string
+}
+
+// LLMUsage 一次调用消耗的token
+type LLMUsage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+}
+
+// LLMReply 大模型的回复
+type LLMReply struct {
+	Content   string
+	ToolCalls []LLMToolCall
+	Model     string
+	Usage     LLMUsage
+}
+
+// LLMProvider 大模型的后端，可以是OpenAI兼容的接口，也可以是用于离线测试的预设回复
+type LLMProvider interface {
+	// Chat 多轮文本对话
+	Chat(ctx context.Context, messages []LLMMessage) (*LLMReply, error)
+	// ChatWithTools 多轮文本对话，大模型可以选择调用其中的工具
+	ChatWithTools(ctx context.Context, messages []LLMMessage, tools []LLMTool) (*LLMReply, error)
+	// Vision 根据提示词描述图片，imageURL 可以是 data URL
+	Vision(ctx context.Context, system, prompt, imageURL string) (*LLMReply, error)
+	// Audio 根据提示词描述语音，data 为原始音频数据
+	Audio(ctx context.Context, system, prompt string, data []byte, format string) (*LLMReply, error)
+	ModelName() string
+}
+
+// OpenAIProvider OpenAI兼容接口的大模型
+type OpenAIProvider struct {
+	client openai.Client
+	model  string
+}
+
+func NewOpenAIProvider(baseURL, apiKey, model string) *OpenAIProvider {
+	return &OpenAIProvider{
+		client: openai.NewClient(option.WithAPIKey(apiKey), option.WithBaseURL(baseURL)),
+		model:  model,
+	}
+}
+
+func (p *OpenAIProvider) ModelName() string {
+	return p.model
+}
+
+func (p *OpenAIProvider) complete(ctx context.Context, messages []openai.ChatCompletionMessageParamUnion, tools ...openai.ChatCompletionToolUnionParam) (*LLMReply, error) {
+	chatCompletion, err := p.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Messages: messages,
+		Model:    p.model,
+		Tools:    tools,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(chatCompletion.Choices) == 0 {
+		return nil, errors.New("LLM没有返回内容")
+	}
+	reply := &LLMReply{
+		Content: chatCompletion.Choices[0].Message.Content,
+		Model:   p.model,
+		Usage: LLMUsage{
+			PromptTokens:     chatCompletion.Usage.PromptTokens,
+			CompletionTokens: chatCompletion.Usage.CompletionTokens,
+		},
+	}
+	for _, call := range chatCompletion.Choices[0].Message.ToolCalls {
+		if call.Type != "function" {
+			continue
+		}
+		reply.ToolCalls = append(reply.ToolCalls, LLMToolCall{Id: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return reply, nil
+}
+
+func openAIMessages(messages []LLMMessage) []openai.ChatCompletionMessageParamUnion {
+	var params []openai.ChatCompletionMessageParamUnion
+	for _, message := range messages {
+		switch message.Role {
+		case LLMRoleSystem:
+			params = append(params, openai.SystemMessage(message.Content))
+		case LLMRoleAssistant:
+			if len(message.ToolCalls) == 0 {
+				params = append(params, openai.AssistantMessage(message.Content))
+				continue
+			}
+			assistant := openai.ChatCompletionAssistantMessageParam{}
+			if message.Content != "" {
+				assistant.Content.OfString = openai.String(message.Content)
+			}
+			for _, call := range message.ToolCalls {
+				assistant.ToolCalls = append(assistant.ToolCalls, openai.ChatCompletionMessageToolCallUnionParam{
+					OfFunction: &openai.ChatCompletionMessageFunctionToolCallParam{
+						ID:       call.Id,
+						Function: openai.ChatCompletionMessageFunctionToolCallFunctionParam{Name: call.Name, Arguments: call.Arguments},
+					},
+				})
+			}
+			params = append(params, openai.ChatCompletionMessageParamUnion{OfAssistant: &assistant})
+		case LLMRoleTool:
+			params = append(params, openai.ToolMessage(message.Content, message.ToolCallId))
+		default:
+			params = append(params, openai.UserMessage(message.Content))
+		}
+	}
+	return params
+}
+
+func (p *OpenAIProvider) Chat(ctx context.Context, messages []LLMMessage) (*LLMReply, error) {
+	return p.complete(ctx, openAIMessages(messages))
+}
+
+func (p *OpenAIProvider) ChatWithTools(ctx context.Context, messages []LLMMessage, tools []LLMTool) (*LLMReply, error) {
+	var params []openai.ChatCompletionToolUnionParam
+	for _, tool := range tools {
+		var parameters openai.FunctionParameters
+		data, err := json.Marshal(tool.Parameters)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &parameters); err != nil {
+			return nil, err
+		}
+		params = append(params, openai.ChatCompletionFunctionTool(openai.FunctionDefinitionParam{
+			Name:        tool.Name,
+			Description: openai.String(tool.Description),
+			Parameters:  parameters,
+		}))
+	}
+	return p.complete(ctx, openAIMessages(messages), params...)
+}
+
+func (p *OpenAIProvider) Vision(ctx context.Context, system, prompt, imageURL string) (*LLMReply, error) {
+	return p.complete(ctx, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(system),
+		openai.UserMessage(prompt),
+		openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+			openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: imageURL, Detail: "auto"}),
+		}),
+	})
+}
+
+func (p *OpenAIProvider) Audio(ctx context.Context, system, prompt string, data []byte, format string) (*LLMReply, error) {
+	return p.complete(ctx, []openai.ChatCompletionMessageParamUnion{
+		openai.SystemMessage(system),
+		openai.UserMessage(prompt),
+		openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
+			openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{Data: base64.StdEncoding.EncodeToString(data), Format: format}),
+		}),
+	})
+}
+
+// MockCall 记录一次对MockProvider的调用
+type MockCall struct {
+	Kind     string
+	Messages []LLMMessage
+	Tools    []LLMTool
+}
+
+// MockProvider 按顺序返回预设回复的大模型，不访问网络，用于测试
+type MockProvider struct {
+	mu      sync.Mutex
+	model   string
+	replies []LLMReply
+	// Handler 不为空时优先使用它根据消息生成回复
+	Handler func(messages []LLMMessage) (string, error)
+	Calls   []MockCall
+}
+
+func NewMockProvider(model string, replies ...string) *MockProvider {
+	p := &MockProvider{model: model}
+	p.Push(replies...)
+	return p
+}
+
+func (p *MockProvider) ModelName() string {
+	return p.model
+}
+
+// Push 追加预设回复
+func (p *MockProvider) Push(replies ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, reply := range replies {
+		p.replies = append(p.replies, LLMReply{Content: reply})
+	}
+}
+
+// PushToolCalls 追加一条请求调用工具的预设回复
+func (p *MockProvider) PushToolCalls(calls ...LLMToolCall) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.replies = append(p.replies, LLMReply{ToolCalls: calls})
+}
+
+func (p *MockProvider) reply(kind string, messages []LLMMessage, tools []LLMTool) (*LLMReply, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Calls = append(p.Calls, MockCall{Kind: kind, Messages: messages, Tools: tools})
+
+	var reply LLMReply
+	if p.Handler != nil {
+		content, err := p.Handler(messages)
+		if err != nil {
+			return nil, err
+		}
+		reply.Content = content
+	} else {
+		if len(p.replies) == 0 {
+			return nil, errors.New("没有预设的LLM回复")
+		}
+		reply = p.replies[0]
+		p.replies = p.replies[1:]
+	}
+
+	var prompt int64
+	for _, message := range messages {
+		prompt += int64(len([]rune(message.Content)))
+	}
+	completion := int64(len([]rune(reply.Content)))
+	for _, call := range reply.ToolCalls {
+		completion += int64(len([]rune(call.Arguments)))
+	}
+	reply.Model = p.model
+	reply.Usage = LLMUsage{PromptTokens: prompt, CompletionTokens: completion}
+	return &reply, nil
+}
+
+func (p *MockProvider) Chat(ctx context.Context, messages []LLMMessage) (*LLMReply, error) {
+	return p.reply("chat", messages, nil)
+}
+
+func (p *MockProvider) ChatWithTools(ctx context.Context, messages []LLMMessage, tools []LLMTool) (*LLMReply, error) {
+	return p.reply("tools", messages, tools)
+}
+
+func (p *MockProvider) Vision(ctx context.Context, system, prompt, imageURL string) (*LLMReply, error) {
+	return p.reply("vision", []LLMMessage{{Role: LLMRoleSystem, Content: system}, {Role: LLMRoleUser, Content: prompt}}, nil)
+}
+
+func (p *MockProvider) Audio(ctx context.Context, system, prompt string, data []byte, format string) (*LLMReply, error) {
+	return p.reply("audio", []LLMMessage{{Role: LLMRoleSystem, Content: system}, {Role: LLMRoleUser, Content: prompt}}, nil)
+}
@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// JSONSchema 由Go类型生成的JSON Schema，只包含校验大模型输出所需的部分
+type JSONSchema struct {
+	Types []string
+	// Description 来自字段的 description 标签，用于向大模型说明参数含义
+	Description          string
+	Properties           map[string]*JSONSchema
+	Required             []string
+	Items                *JSONSchema
+	AdditionalProperties *JSONSchema
+}
+
+func (s *JSONSchema) MarshalJSON() ([]byte, error) {
+	data := map[string]any{}
+	switch len(s.Types) {
+	case 0:
+	case 1:
+		data["type"] = s.Types[0]
+	default:
+		data["type"] = s.Types
+	}
+	if s.Description != "" {
+		data["description"] = s.Description
+	}
+	if s.Properties != nil {
+		data["properties"] = s.Properties
+	}
+	if len(s.Required) > 0 {
+		data["required"] = s.Required
+	}
+	if s.Items != nil {
+		data["items"] = s.Items
+	}
+	if s.AdditionalProperties != nil {
+		data["additionalProperties"] = s.AdditionalProperties
+	}
+	return json.Marshal(data)
+}
+
+func (s *JSONSchema) String() string {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// SchemaOf 根据T的字段和json标签生成JSON Schema
+func SchemaOf[T any]() *JSONSchema {
+	return schemaOfType(reflect.TypeOf((*T)(nil)).Elem(), map[reflect.Type]bool{})
+}
+
+func schemaOfType(t reflect.Type, seen map[reflect.Type]bool) *JSONSchema {
+	if t == timeType {
+		return &JSONSchema{Types: []string{"string"}}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaOfType(t.Elem(), seen)
+		if len(schema.Types) > 0 {
+			schema.Types = append(schema.Types, "null")
+		}
+		return schema
+	case reflect.Bool:
+		return &JSONSchema{Types: []string{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &JSONSchema{Types: []string{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &JSONSchema{Types: []string{"number"}}
+	case reflect.String:
+		return &JSONSchema{Types: []string{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &JSONSchema{Types: []string{"string"}}
+		}
+		// nil 切片序列化为 null，反序列化时也接受 null
+		return &JSONSchema{Types: []string{"array", "null"}, Items: schemaOfType(t.Elem(), seen)}
+	case reflect.Map:
+		return &JSONSchema{Types: []string{"object", "null"}, AdditionalProperties: schemaOfType(t.Elem(), seen)}
+	case reflect.Struct:
+		// 递归的类型不再展开
+		if seen[t] {
+			return &JSONSchema{Types: []string{"object"}}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		schema := &JSONSchema{Types: []string{"object"}, Properties: map[string]*JSONSchema{}}
+		for i := range t.NumField() {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, omitEmpty, skip := parseJSONTag(field)
+			if skip {
+				continue
+			}
+			property := schemaOfType(field.Type, seen)
+			property.Description = field.Tag.Get("description")
+			schema.Properties[name] = property
+			if !omitEmpty {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+		return schema
+	default:
+		// interface 等类型不做限制
+		return &JSONSchema{}
+	}
+}
+
+func parseJSONTag(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" || option == "omitzero" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+// Validate 校验反序列化到 any 的JSON数据，返回所有不符合的地方
+func (s *JSONSchema) Validate(value any) []string {
+	var problems []string
+	s.validate(value, "$", &problems)
+	return problems
+}
+
+func jsonTypeOf(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func (s *JSONSchema) allows(actual string) bool {
+	if len(s.Types) == 0 {
+		return true
+	}
+	for _, t := range s.Types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *JSONSchema) validate(value any, path string, problems *[]string) {
+	actual := jsonTypeOf(value)
+	if !s.allows(actual) {
+		*problems = append(*problems, fmt.Sprintf("%s 应为 %s，实际为 %s", path, strings.Join(s.Types, " 或 "), actual))
+		return
+	}
+
+	switch v := value.(type) {
+	case []any:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range v {
+			s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), problems)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*problems = append(*problems, fmt.Sprintf("%s 缺少字段 %s", path, name))
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if property, ok := s.Properties[key]; ok {
+				property.validate(v[key], path+"."+key, problems)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(v[key], path+"."+key, problems)
+			}
+		}
+	}
+}
+
+// LLMOutputError 大模型多次返回的内容都无法解析为需要的格式
+type LLMOutputError struct {
+	Model    string
+	Attempts int
+	// Output 最后一次的原始输出
+	Output string
+	Err    error
+}
+
+func (e *LLMOutputError) Error() string {
+	return fmt.Sprintf("LLM %s 在 %d 次尝试后仍未返回正确格式: %v", e.Model, e.Attempts, e.Err)
+}
+
+func (e *LLMOutputError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+func TestMain(m *testing.M) {
+	Logger = utils.NewProtocolLogger(utils.NewBotLogger(&utils.LogConfig{Level: utils.ErrorLevel}))
+	os.Exit(m.Run())
+}
+
+type testJsonReturn struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestLoopGetJsonReturn(t *testing.T) {
+	tests := []struct {
+		name    string
+		replies []string
+		// handler 不为空时代替预设回复
+		handler  func(calls int) (string, error)
+		want     *testJsonReturn
+		calls    int
+		attempts int
+		quota    bool
+	}{
+		{
+			name:    "直接返回",
+			replies: []string{`{"name":"高数","count":1}`},
+			want:    &testJsonReturn{Name: "高数", Count: 1},
+			calls:   1,
+		},
+		{
+			name:    "去掉思考标签和代码块",
+			replies: []string{"<thinking>想一想</thinking>\n```json\n{\"name\":\"概统\",\"count\":2}\n```"},
+			want:    &testJsonReturn{Name: "概统", Count: 2},
+			calls:   1,
+		},
+		{
+			name:    "格式错误后修正",
+			replies: []string{`{"name":"高数"}`, `{"name":"高数","count":3}`},
+			want:    &testJsonReturn{Name: "高数", Count: 3},
+			calls:   2,
+		},
+		{
+			name:     "多次格式错误",
+			replies:  []string{"不是JSON", "不是JSON", "不是JSON", "不是JSON", `{"name":"高数","count":1}`},
+			calls:    jsonRepairTimes + 1,
+			attempts: jsonRepairTimes + 1,
+		},
+		{
+			name: "请求失败后重试",
+			handler: func(calls int) (string, error) {
+				if calls == 1 {
+					return "", errors.New("网络错误")
+				}
+				return `{"name":"线代","count":4}`, nil
+			},
+			want:  &testJsonReturn{Name: "线代", Count: 4},
+			calls: 2,
+		},
+		{
+			name: "额度用完不重试",
+			handler: func(calls int) (string, error) {
+				return "", &LLMQuotaError{Scope: "个人", Limit: 1, Unit: "次请求"}
+			},
+			calls: 1,
+			quota: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := NewMockProvider("mock", tt.replies...)
+			if tt.handler != nil {
+				calls := 0
+				mock.Handler = func(messages []LLMMessage) (string, error) {
+					calls++
+					return tt.handler(calls)
+				}
+			}
+
+			got, err := LoopGetJsonReturn[testJsonReturn](context.Background(), mock, "测试")
+			if len(mock.Calls) != tt.calls {
+				t.Fatalf("调用次数 = %d, 期望 %d", len(mock.Calls), tt.calls)
+			}
+
+			var quotaErr *LLMQuotaError
+			if errors.As(err, &quotaErr) != tt.quota {
+				t.Fatalf("额度错误 = %v, 期望 %v", err, tt.quota)
+			}
+			if tt.attempts > 0 {
+				var outputErr *LLMOutputError
+				if !errors.As(err, &outputErr) || outputErr.Attempts != tt.attempts {
+					t.Fatalf("错误 = %v, 期望 %d 次格式错误", err, tt.attempts)
+				}
+			}
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("期望返回错误, 得到 %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("返回错误 %v", err)
+			}
+			if *got != *tt.want {
+				t.Fatalf("结果 = %+v, 期望 %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoopGetJsonReturnRepairPrompt(t *testing.T) {
+	mock := NewMockProvider("mock", `{"name":"高数"}`, `{"name":"高数","count":1}`)
+	if _, err := LoopGetJsonReturn[testJsonReturn](context.Background(), mock, "测试"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 第二轮需要带上上一次的回复和格式错误
+	messages := mock.Calls[1].Messages
+	if len(messages) != 4 {
+		t.Fatalf("第二轮消息数 = %d, 期望 4", len(messages))
+	}
+	if messages[2].Role != LLMRoleAssistant || messages[2].Content != `{"name":"高数"}` {
+		t.Fatalf("没有带上上一次的回复: %+v", messages[2])
+	}
+	if messages[3].Role != LLMRoleUser || !strings.Contains(messages[3].Content, "count") {
+		t.Fatalf("没有反馈格式错误: %+v", messages[3])
+	}
+}
+
+func TestGetLLMChooseCourse(t *testing.T) {
+	courseData := FormatCourseData{
+		{Id: 1, Name: "高等数学A(上)"},
+		{Id: 2, Name: "概率论与数理统计"},
+		{Id: 3, Name: "大学物理"},
+	}
+	recentCourseData := FormatCourseData{courseData[1]}
+
+	tests := []struct {
+		name      string
+		replies   []string
+		want      int
+		ambiguous bool
+	}{
+		{name: "选择课程", replies: []string{`{"course":2}`}, want: 2},
+		{name: "格式错误后选择课程", replies: []string{`{"course":"高数"}`, `{"course":1}`}, want: 1},
+		{name: "无法确定", replies: []string{`{"course":null}`}, ambiguous: true},
+		{name: "一直格式错误", replies: []string{"高数", "高数", "高数", "高数"}},
+	}
+
+	defer func(choice LLMProvider) { Llm.Choice = choice }(Llm.Choice)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Llm.Choice = NewMockProvider("mock", tt.replies...)
+
+			course, err := GetLLMChooseCourse(context.Background(), &courseData, &recentCourseData, "课件", nil)
+			var ambiguousErr *AmbiguousCourseError
+			if errors.As(err, &ambiguousErr) != tt.ambiguous {
+				t.Fatalf("错误 = %v, 期望无法确定课程 %v", err, tt.ambiguous)
+			}
+			if tt.want == 0 {
+				if err == nil {
+					t.Fatalf("期望返回错误, 得到 %+v", course)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("返回错误 %v", err)
+			}
+			if course.Id != tt.want {
+				t.Fatalf("课程 = %d, 期望 %d", course.Id, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vintcessun/XMU-CM-Bot/config"
+)
+
+const llmUsageBucket = "llm_usage"
+const llmUsageDailyBucket = "llm_usage_daily"
+
+// 每日汇总的范围
+const (
+	llmUsageScopeUser  = "user"
+	llmUsageScopeGroup = "group"
+	llmUsageScopeModel = "model"
+)
+
+var llmQuota config.LLMQuotaConfig
+var llmUsageMu sync.Mutex
+
+// llmUsageRetention 调用记录保存的时间，每日汇总不受影响
+var llmUsageRetention = 30 * 24 * time.Hour
+
+// llmUsagePruneInterval 清理过期调用记录的间隔
+var llmUsagePruneInterval = time.Hour
+var llmUsageLastPrune time.Time
+
+type llmCallerKey struct{}
+
+// WithLLMCaller 在上下文中记录调用大模型的用户和群，用于统计用量和限制额度
+func WithLLMCaller(ctx context.Context, target NotifyTarget) context.Context {
+	return context.WithValue(ctx, llmCallerKey{}, target)
+}
+
+// LLMCallerFrom 获取上下文中调用大模型的用户和群，没有记录时为空
+func LLMCallerFrom(ctx context.Context) NotifyTarget {
+	if ctx == nil {
+		return NotifyTarget{}
+	}
+	target, _ := ctx.Value(llmCallerKey{}).(NotifyTarget)
+	return target
+}
+
+// LLMUsageRecord 一次大模型调用的记录
+type LLMUsageRecord struct {
+	Time time.Time `json:"time"`
+	// Kind 为 Text、Choice 或 Dynamic
+	Kind             string `json:"kind"`
+	Model            string `json:"model"`
+	Uin              uint32 `json:"uin"`
+	GroupUin         uint32 `json:"group_uin"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	LatencyMs        int64  `json:"latency_ms"`
+	Error            string `json:"error,omitempty"`
+}
+
+// LLMUsageStat 一段时间内的大模型用量
+type LLMUsageStat struct {
+	Requests         int64 `json:"requests"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	LatencyMs        int64 `json:"latency_ms"`
+}
+
+func (s *LLMUsageStat) Tokens() int64 {
+	return s.PromptTokens + s.CompletionTokens
+}
+
+func (s *LLMUsageStat) add(other *LLMUsageStat) {
+	s.Requests += other.Requests
+	s.PromptTokens += other.PromptTokens
+	s.CompletionTokens += other.CompletionTokens
+	s.LatencyMs += other.LatencyMs
+}
+
+func (s *LLMUsageStat) ToString() string {
+	if s.Requests == 0 {
+		return "0 次请求"
+	}
+	return fmt.Sprintf("%d 次请求，%d tokens (输入 %d，输出 %d)，平均耗时 %.1fs", s.Requests, s.Tokens(), s.PromptTokens, s.CompletionTokens, float64(s.LatencyMs)/float64(s.Requests)/1000)
+}
+
+func llmUsageDay(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func llmUsageDailyKey(day, scope, id string) []byte {
+	return []byte(fmt.Sprintf("%s/%s/%s", day, scope, id))
+}
+
+func getLLMUsageDaily(day, scope, id string) *LLMUsageStat {
+	stat, ok, err := DBGet[LLMUsageStat](llmUsageDailyBucket, llmUsageDailyKey(day, scope, id))
+	if err != nil {
+		Logger.Warning("读取大模型用量失败 %v", err)
+	}
+	if !ok {
+		return &LLMUsageStat{}
+	}
+	return stat
+}
+
+// pruneLLMUsage 删除超过保存时间的调用记录，键以纳秒时间开头，按键的顺序删除即可
+func pruneLLMUsage(now time.Time) {
+	if now.Sub(llmUsageLastPrune) < llmUsagePruneInterval {
+		return
+	}
+	llmUsageLastPrune = now
+
+	before := []byte(fmt.Sprintf("%019d", now.Add(-llmUsageRetention).UnixNano()))
+	if err := DBDeleteBefore(llmUsageBucket, before); err != nil {
+		Logger.Warning("清理大模型调用记录失败 %v", err)
+	}
+}
+
+// recordLLMUsage 保存调用记录，同时累加用户、群和模型当天的用量
+func recordLLMUsage(record *LLMUsageRecord) {
+	if Db.db == nil {
+		return
+	}
+	llmUsageMu.Lock()
+	defer llmUsageMu.Unlock()
+
+	key := []byte(fmt.Sprintf("%019d-%d", record.Time.UnixNano(), record.Uin))
+	if err := DBPut(llmUsageBucket, key, record); err != nil {
+		Logger.Warning("保存大模型调用记录失败 %v", err)
+	}
+	pruneLLMUsage(record.Time)
+
+	stat := &LLMUsageStat{Requests: 1, PromptTokens: record.PromptTokens, CompletionTokens: record.CompletionTokens, LatencyMs: record.LatencyMs}
+	day := llmUsageDay(record.Time)
+	scopes := [][2]string{{llmUsageScopeModel, record.Kind + ":" + record.Model}}
+	if record.Uin != 0 {
+		scopes = append(scopes, [2]string{llmUsageScopeUser, fmt.Sprint(record.Uin)})
+	}
+	if record.GroupUin != 0 {
+		scopes = append(scopes, [2]string{llmUsageScopeGroup, fmt.Sprint(record.GroupUin)})
+	}
+	for _, scope := range scopes {
+		daily := getLLMUsageDaily(day, scope[0], scope[1])
+		daily.add(stat)
+		if err := DBPut(llmUsageDailyBucket, llmUsageDailyKey(day, scope[0], scope[1]), daily); err != nil {
+			Logger.Warning("保存大模型用量失败 %v", err)
+		}
+	}
+}
+
+// getLLMUsage 汇总最近days天的用量，包含今天
+func getLLMUsage(days int, scope, id string) *LLMUsageStat {
+	total := &LLMUsageStat{}
+	if Db.db == nil {
+		return total
+	}
+	now := time.Now()
+	for i := range max(days, 1) {
+		total.add(getLLMUsageDaily(llmUsageDay(now.AddDate(0, 0, -i)), scope, id))
+	}
+	return total
+}
+
+// GetUserLLMUsage 获取用户最近days天的大模型用量
+func GetUserLLMUsage(uin uint32, days int) *LLMUsageStat {
+	return getLLMUsage(days, llmUsageScopeUser, fmt.Sprint(uin))
+}
+
+// GetGroupLLMUsage 获取群最近days天的大模型用量
+func GetGroupLLMUsage(groupUin uint32, days int) *LLMUsageStat {
+	return getLLMUsage(days, llmUsageScopeGroup, fmt.Sprint(groupUin))
+}
+
+// LLMModelUsage 一个模型的用量
+type LLMModelUsage struct {
+	Model string
+	Stat  *LLMUsageStat
+}
+
+// GetModelLLMUsage 获取最近days天各个模型的用量，按token数从高到低排序
+func GetModelLLMUsage(days int) ([]*LLMModelUsage, error) {
+	if Db.db == nil {
+		return nil, nil
+	}
+	now := time.Now()
+	models := make(map[string]*LLMUsageStat)
+	for i := range max(days, 1) {
+		// 键为 日期/model/模型名，模型名中可能包含 /，按每天的前缀读取
+		prefix := llmUsageDailyKey(llmUsageDay(now.AddDate(0, 0, -i)), llmUsageScopeModel, "")
+		err := DBForEachPrefix(llmUsageDailyBucket, prefix, func(key []byte, value *LLMUsageStat) error {
+			model := string(key[len(prefix):])
+			if _, ok := models[model]; !ok {
+				models[model] = &LLMUsageStat{}
+			}
+			models[model].add(value)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var data []*LLMModelUsage
+	for model, stat := range models {
+		data = append(data, &LLMModelUsage{Model: model, Stat: stat})
+	}
+	sort.Slice(data, func(i, j int) bool {
+		return data[i].Stat.Tokens() > data[j].Stat.Tokens()
+	})
+	return data, nil
+}
+
+// LLMQuotaError 今日的大模型额度已经用完
+type LLMQuotaError struct {
+	// Scope 为 "个人" 或 "本群"
+	Scope string
+	Limit int64
+	Unit  string
+}
+
+func (e *LLMQuotaError) Error() string {
+	return fmt.Sprintf("%s今日的大模型额度已用完 (上限 %d %s)，请明天再试", e.Scope, e.Limit, e.Unit)
+}
+
+func checkLLMQuotaStat(stat *LLMUsageStat, scope string, tokens, requests int64) error {
+	if requests > 0 && stat.Requests >= requests {
+		return &LLMQuotaError{Scope: scope, Limit: requests, Unit: "次请求"}
+	}
+	if tokens > 0 && stat.Tokens() >= tokens {
+		return &LLMQuotaError{Scope: scope, Limit: tokens, Unit: "tokens"}
+	}
+	return nil
+}
+
+// GetLLMQuota 获取配置的每日额度
+func GetLLMQuota() config.LLMQuotaConfig {
+	return llmQuota
+}
+
+// CheckLLMQuota 在调用大模型前检查上下文中的用户和群今日的额度
+func CheckLLMQuota(ctx context.Context) error {
+	caller := LLMCallerFrom(ctx)
+	if caller.Uin != 0 {
+		if err := checkLLMQuotaStat(GetUserLLMUsage(caller.Uin, 1), "个人", llmQuota.UserTokens, llmQuota.UserRequests); err != nil {
+			return err
+		}
+	}
+	if caller.GroupUin != 0 {
+		if err := checkLLMQuotaStat(GetGroupLLMUsage(caller.GroupUin, 1), "本群", llmQuota.GroupTokens, llmQuota.GroupRequests); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// meteredProvider 在调用前检查额度，调用后记录用量
+type meteredProvider struct {
+	kind     string
+	provider LLMProvider
+}
+
+func newMeteredProvider(kind string, provider LLMProvider) *meteredProvider {
+	return &meteredProvider{kind: kind, provider: provider}
+}
+
+func (p *meteredProvider) ModelName() string {
+	return p.provider.ModelName()
+}
+
+func (p *meteredProvider) call(ctx context.Context, f func() (*LLMReply, error)) (*LLMReply, error) {
+	if err := CheckLLMQuota(ctx); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	reply, err := f()
+	caller := LLMCallerFrom(ctx)
+	record := LLMUsageRecord{
+		Time:      start,
+		Kind:      p.kind,
+		Model:     p.provider.ModelName(),
+		Uin:       caller.Uin,
+		GroupUin:  caller.GroupUin,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	} else {
+		record.PromptTokens = reply.Usage.PromptTokens
+		record.CompletionTokens = reply.Usage.CompletionTokens
+	}
+	recordLLMUsage(&record)
+	return reply, err
+}
+
+func (p *meteredProvider) Chat(ctx context.Context, messages []LLMMessage) (*LLMReply, error) {
+	return p.call(ctx, func() (*LLMReply, error) {
+		return p.provider.Chat(ctx, messages)
+	})
+}
+
+func (p *meteredProvider) ChatWithTools(ctx context.Context, messages []LLMMessage, tools []LLMTool) (*LLMReply, error) {
+	return p.call(ctx, func() (*LLMReply, error) {
+		return p.provider.ChatWithTools(ctx, messages, tools)
+	})
+}
+
+func (p *meteredProvider) Vision(ctx context.Context, system, prompt, imageURL string) (*LLMReply, error) {
+	return p.call(ctx, func() (*LLMReply, error) {
+		return p.provider.Vision(ctx, system, prompt, imageURL)
+	})
+}
+
+func (p *meteredProvider) Audio(ctx context.Context, system, prompt string, data []byte, format string) (*LLMReply, error) {
+	return p.call(ctx, func() (*LLMReply, error) {
+		return p.provider.Audio(ctx, system, prompt, data, format)
+	})
+}
@@ -38,6 +38,20 @@ func (l *LoginData) delete(key uint32) {
 	l.m.Delete(key)
 }
 
+func (l *LoginData) rangeData(f func(key uint32, value string) bool) {
+	l.m.Range(func(k, v any) bool {
+		key, ok := k.(uint32)
+		if !ok {
+			return true
+		}
+		value, ok := v.(string)
+		if !ok {
+			return true
+		}
+		return f(key, value)
+	})
+}
+
 type LoginStruct struct {
 	Data      *LoginData
 	cacheFile string
@@ -100,6 +114,11 @@ func (l *LoginStruct) Delete(uin uint32) {
 	*l.dirty = true
 }
 
+// Range 遍历所有已登录用户的session，f返回false时停止遍历
+func (l *LoginStruct) Range(f func(uin uint32, session string) bool) {
+	l.Data.rangeData(f)
+}
+
 func (l *LoginStruct) runTaskLoop() {
 	const rangeDelay = 1 * time.Second
 
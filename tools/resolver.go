@@ -0,0 +1,330 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 本地匹配课程的阈值，最高分低于 resolveMinScore 或前两名相差小于 resolveMargin 时交给大模型选择
+var (
+	resolveMinScore = 40.0
+	resolveMargin   = 15.0
+)
+
+// courseAbbreviations 无法通过按顺序包含的字推断出的常见简称
+var courseAbbreviations = map[string][]string{
+	"近代史": {"中国近现代史纲要"},
+	"史纲":  {"中国近现代史纲要"},
+	"思修":  {"思想道德修养与法律基础", "思想道德与法治"},
+	"思政":  {"思想道德与法治", "思想政治"},
+	"概统":  {"概率论与数理统计"},
+	"概率论": {"概率论与数理统计"},
+	"os":  {"操作系统"},
+	"ds":  {"数据结构"},
+	"cpp": {"c++"},
+}
+
+// pinyinInitials 课程名中常见汉字的拼音首字母
+var pinyinInitials = map[byte]string{
+	'a': "安案",
+	'b': "本编标比必部博不变毕版办保北半表病步波",
+	'c': "程材测成财策产城传创词从操初抽场车常出处采存参层础磁",
+	'd': "大代电导德地动读道单第调度点端对队典订多东等",
+	'e': "二儿",
+	'f': "法分方发风复服辅非符放翻防范仿纺",
+	'g': "高概工管国规构光关公古感广共观个格港估果",
+	'h': "化函汉合会环画核互航海和宏活换后衡话何号汇",
+	'j': "计基经机纪建结教近军金技解级际集鉴精简决交节竞健镜境件纲积据济",
+	'k': "科可控课口考开空库跨克",
+	'l': "论理律类历力量流林路离逻旅练利临粒率领劳络",
+	'm': "马毛模美民面媒命密名目貌码",
+	'n': "能农内年拟",
+	'o': "欧",
+	'p': "平品评普篇配培判频剖",
+	'q': "期器企权区气群强曲全前球清情求趣",
+	'r': "人日软入热认融",
+	's': "数社设生思史事式实时世商市示术视试摄算素识水声输色散审身食书手刷索随塑势",
+	't': "体统通图题特听土态天拓推",
+	'w': "文物微外网务问无位维卫稳舞武",
+	'x': "学线性系信现新形心写行习想修析限象序选项宪戏细讯小效协销刑向",
+	'y': "原语与英应用研艺医药义易业音影游运因验育演源引有院域预营译要言",
+	'z': "治政制主中专组哲资自作综证走职质智知总战展着装指造择子织泽字值",
+}
+
+var pinyinInitialMap = func() map[rune]rune {
+	data := make(map[rune]rune)
+	for initial, chars := range pinyinInitials {
+		for _, char := range chars {
+			data[char] = rune(initial)
+		}
+	}
+	return data
+}()
+
+var (
+	academicYearPattern = regexp.MustCompile(`\d{4}-\d{4}|\d{4}学年`)
+	asciiWordPattern    = regexp.MustCompile(`[a-z+]{2,}`)
+)
+
+// CourseCandidate 本地匹配课程的候选项
+type CourseCandidate struct {
+	Course *FormatCourseInside
+	Score  float64
+}
+
+// semesterHint 指令中提到的学期，字段为空时不限制
+type semesterHint struct {
+	academicYear string
+	term         string
+}
+
+func (h *semesterHint) match(semester string) bool {
+	return strings.HasPrefix(semester, h.academicYear) && strings.Contains(semester, h.term)
+}
+
+// parseSemester 将 GetSemesterInfo 的结果拆分为学年和学期
+func parseSemester(semester string) semesterHint {
+	var start, end int
+	if _, err := fmt.Sscanf(semester, "%d-%d学年", &start, &end); err != nil {
+		return semesterHint{}
+	}
+	hint := semesterHint{academicYear: fmt.Sprintf("%d-%d学年", start, end)}
+	for _, semesterTerm := range semesterTerms {
+		if strings.Contains(semester, semesterTerm.Name) {
+			hint.term = semesterTerm.Name
+		}
+	}
+	return hint
+}
+
+// previousSemester 返回上一个学期，夏季学期的上一个学期为春季学期
+func previousSemester(current semesterHint) semesterHint {
+	var start, end int
+	if _, err := fmt.Sscanf(current.academicYear, "%d-%d学年", &start, &end); err != nil {
+		return semesterHint{}
+	}
+	for i, semesterTerm := range semesterTerms {
+		if semesterTerm.Name != current.term {
+			continue
+		}
+		if i == 0 {
+			return semesterHint{academicYear: fmt.Sprintf("%d-%d学年", start-1, start), term: semesterTerms[1].Name}
+		}
+		return semesterHint{academicYear: current.academicYear, term: semesterTerms[i-1].Name}
+	}
+	return semesterHint{}
+}
+
+// getSemesterHint 从指令中提取学期，没有提到学期时返回false
+func getSemesterHint(command string) (semesterHint, bool) {
+	switch {
+	case strings.Contains(command, "本学期") || strings.Contains(command, "这学期") || strings.Contains(command, "这个学期"):
+		return parseSemester(CurrentSemester()), true
+	case strings.Contains(command, "上学期") || strings.Contains(command, "上个学期"):
+		return previousSemester(parseSemester(CurrentSemester())), true
+	}
+
+	var hint semesterHint
+	if year := academicYearPattern.FindString(command); year != "" {
+		hint.academicYear, _ = ParseAcademicYear(year)
+	}
+	for _, keyword := range []string{"秋季", "春季", "夏季", "小学期", "第一学期", "第二学期", "第三学期"} {
+		if strings.Contains(command, keyword) {
+			hint.term, _ = ParseSemesterTerm(strings.TrimSuffix(keyword, "季"))
+			break
+		}
+	}
+	return hint, hint.academicYear != "" || hint.term != ""
+}
+
+// courseQueryFields 去掉指令和选项，返回去掉标点并转为小写的各个部分
+func courseQueryFields(text string) []string {
+	var fields []string
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(field, "/") || strings.HasPrefix(field, "--") {
+			continue
+		}
+		field = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) && r != '+' {
+				return -1
+			}
+			return unicode.ToLower(r)
+		}, field)
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+func normalizeCourseText(text string) string {
+	return strings.Join(courseQueryFields(text), "")
+}
+
+// courseQueryWords 指令中的英文单词，只在同一部分内查找，避免 "ds ppt" 被当作一个单词
+func courseQueryWords(command string) []string {
+	var words []string
+	for _, field := range courseQueryFields(command) {
+		words = append(words, asciiWordPattern.FindAllString(field, -1)...)
+	}
+	return words
+}
+
+// longestCommonSubstring 返回两个字符串最长公共子串的长度
+func longestCommonSubstring(a, b []rune) int {
+	best := 0
+	prev := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1] + 1
+				best = max(best, cur[j])
+			}
+		}
+		prev = cur
+	}
+	return best
+}
+
+// longestAbbreviation 查找指令中以课程名首字开头、且按顺序包含在课程名中的最长片段，如 "高数" 之于 "高等数学"
+func longestAbbreviation(query, name []rune) int {
+	best := 0
+	for i := range query {
+		if query[i] != name[0] {
+			continue
+		}
+		k := 1
+		length := 1
+		for j := i + 1; j < len(query); j++ {
+			for k < len(name) && name[k] != query[j] {
+				k++
+			}
+			if k == len(name) {
+				break
+			}
+			k++
+			length++
+		}
+		best = max(best, length)
+	}
+	return best
+}
+
+func courseInitials(name []rune) string {
+	var initials []rune
+	for _, r := range name {
+		if initial, ok := pinyinInitialMap[r]; ok {
+			initials = append(initials, initial)
+		} else if r < unicode.MaxASCII {
+			initials = append(initials, r)
+		}
+	}
+	return string(initials)
+}
+
+// scoreCourseName 计算课程名与指令的匹配程度，满分为100
+func scoreCourseName(query string, words []string, name string) float64 {
+	if name == "" {
+		return 0
+	}
+	if strings.Contains(query, name) {
+		return 100
+	}
+
+	queryRunes := []rune(query)
+	nameRunes := []rune(name)
+	var score float64
+
+	for abbreviation, expansions := range courseAbbreviations {
+		if asciiWordPattern.MatchString(abbreviation) {
+			if !slices.Contains(words, abbreviation) {
+				continue
+			}
+		} else if !strings.Contains(query, abbreviation) {
+			continue
+		}
+		for _, expansion := range expansions {
+			if strings.Contains(name, expansion) {
+				score = max(score, 90)
+			}
+		}
+	}
+
+	if length := longestCommonSubstring(queryRunes, nameRunes); length >= 2 {
+		score = max(score, 20+70*float64(length)/float64(len(nameRunes)))
+	}
+	if length := longestAbbreviation(queryRunes, nameRunes); length >= 2 {
+		score = max(score, 50+30*float64(length)/float64(len(nameRunes)))
+	}
+
+	initials := courseInitials(nameRunes)
+	for _, word := range words {
+		switch {
+		case word == initials:
+			score = max(score, 85)
+		case strings.HasPrefix(initials, word):
+			score = max(score, 50+30*float64(len(word))/float64(len(initials)))
+		}
+	}
+	return score
+}
+
+// RankCourses 在本地按照课程名、学期和最近访问对课程打分，返回得分大于0的课程，按分数从高到低排序
+func RankCourses(courseData, recentCourseData *FormatCourseData, command string) []*CourseCandidate {
+	query := normalizeCourseText(command)
+	words := courseQueryWords(command)
+	hint, hasHint := getSemesterHint(command)
+	current := CurrentSemester()
+
+	recent := make(map[int]int)
+	if recentCourseData != nil {
+		for i, course := range *recentCourseData {
+			if _, ok := recent[course.Id]; !ok {
+				recent[course.Id] = i
+			}
+		}
+	}
+
+	var candidates []*CourseCandidate
+	for _, course := range *courseData {
+		score := scoreCourseName(query, words, normalizeCourseText(course.Name))
+		if score == 0 {
+			continue
+		}
+
+		switch {
+		case hasHint && hint.match(course.Semester):
+			score += 25
+		case hasHint:
+			score -= 25
+		case course.Semester == current:
+			score += 8
+		}
+		if i, ok := recent[course.Id]; ok {
+			score += max(10-float64(i), 2)
+		}
+		candidates = append(candidates, &CourseCandidate{Course: course, Score: score})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	return candidates
+}
+
+// ResolveCourse 在本地匹配指令中的课程，无法确定时返回false
+func ResolveCourse(courseData, recentCourseData *FormatCourseData, command string) (*FormatCourseInside, bool) {
+	candidates := RankCourses(courseData, recentCourseData, command)
+	if len(candidates) == 0 || candidates[0].Score < resolveMinScore {
+		return nil, false
+	}
+	if len(candidates) > 1 && candidates[0].Score-candidates[1].Score < resolveMargin {
+		return nil, false
+	}
+	return candidates[0].Course, true
+}
@@ -0,0 +1,77 @@
+package tools
+
+import "testing"
+
+func TestPinyinInitialsUnique(t *testing.T) {
+	seen := make(map[rune]byte)
+	for initial, chars := range pinyinInitials {
+		for _, char := range chars {
+			if other, ok := seen[char]; ok {
+				t.Errorf("%c 同时属于 %c 和 %c", char, other, initial)
+			}
+			seen[char] = initial
+		}
+	}
+}
+
+func TestResolveCourse(t *testing.T) {
+	current := CurrentSemester()
+	previousHint := previousSemester(parseSemester(current))
+	previous := previousHint.academicYear + previousHint.term
+
+	courseData := FormatCourseData{
+		{Id: 1, Name: "高等数学A(上)", Semester: current},
+		{Id: 2, Name: "概率论与数理统计", Semester: current},
+		{Id: 3, Name: "马克思主义基本原理", Semester: current},
+		{Id: 4, Name: "大学物理", Semester: current},
+		{Id: 5, Name: "离散数学", Semester: current},
+	}
+	semesterData := FormatCourseData{
+		{Id: 1, Name: "高等数学A(上)", Semester: previous},
+		{Id: 2, Name: "高等数学A(下)", Semester: current},
+	}
+	tieData := FormatCourseData{
+		{Id: 1, Name: "高等数学A(上)", Semester: current},
+		{Id: 2, Name: "高等数学A(下)", Semester: current},
+	}
+
+	tests := []struct {
+		name       string
+		courseData FormatCourseData
+		recent     FormatCourseData
+		command    string
+		// want 为0时表示本地无法确定，需要交给大模型
+		want int
+	}{
+		{name: "简称", courseData: courseData, command: "/download 高数", want: 1},
+		{name: "拼音首字母", courseData: courseData, command: "/download gdsx", want: 1},
+		{name: "拼音首字母前缀", courseData: courseData, command: "/download mkszy", want: 3},
+		{name: "常见简称", courseData: courseData, command: "/download 概统", want: 2},
+		{name: "完整课程名", courseData: courseData, command: "/download 大学物理 --all", want: 4},
+		{name: "上学期", courseData: semesterData, command: "/download 上学期 高数", want: 1},
+		{name: "本学期", courseData: semesterData, command: "/download 本学期 高数", want: 2},
+		{name: "没有匹配", courseData: courseData, command: "/download 课件"},
+		{name: "分数接近", courseData: courseData, command: "/download 数学"},
+		{name: "同名不同学期未指明", courseData: semesterData, command: "/download 高数"},
+		{name: "同学期同名", courseData: tieData, command: "/download 高数"},
+		{name: "最近访问不足以区分", courseData: tieData, recent: FormatCourseData{tieData[1]}, command: "/download 高数"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			course, ok := ResolveCourse(&tt.courseData, &tt.recent, tt.command)
+			if tt.want == 0 {
+				if ok {
+					t.Fatalf("期望交给大模型, 本地匹配到 %s", course.Name)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("本地没有匹配到课程, 候选: %+v", RankCourses(&tt.courseData, &tt.recent, tt.command))
+			}
+			if course.Id != tt.want {
+				t.Fatalf("课程 = %d %s, 期望 %d", course.Id, course.Name, tt.want)
+			}
+		})
+	}
+}
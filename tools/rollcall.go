@@ -0,0 +1,213 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+type APIRollcall struct {
+	RollcallId     int    `json:"rollcall_id"`
+	CourseId       int    `json:"course_id"`
+	CourseTitle    string `json:"course_title"`
+	Title          string `json:"title"`
+	CreatedByName  string `json:"created_by_name"`
+	IsNumber       bool   `json:"is_number"`
+	IsRadar        bool   `json:"is_radar"`
+	RollcallStatus string `json:"rollcall_status"`
+	Status         string `json:"status"`
+	RollcallTime   string `json:"rollcall_time"`
+}
+
+type APIRollcalls struct {
+	Rollcalls []*APIRollcall `json:"rollcalls"`
+}
+
+type RollcallType int
+
+const (
+	RollcallTypeNormal RollcallType = iota
+	RollcallTypeNumber
+	RollcallTypeRadar
+)
+
+func (t RollcallType) String() string {
+	switch t {
+	case RollcallTypeNumber:
+		return "数字签到"
+	case RollcallTypeRadar:
+		return "雷达签到"
+	default:
+		return "普通签到"
+	}
+}
+
+// Rollcall 表示一次签到
+type Rollcall struct {
+	Id        int
+	CourseId  int
+	Course    string
+	Title     string
+	Teacher   string
+	Type      RollcallType
+	StartTime time.Time
+	// Status 为签到本身的状态，如 in_progress
+	Status string
+	// Answered 表示当前用户是否已经完成签到
+	Answered bool
+}
+
+// IsOpen 签到是否仍在进行
+func (r *Rollcall) IsOpen() bool {
+	return r.Status == "in_progress"
+}
+
+func (r *Rollcall) ToString() string {
+	start := "未知"
+	if !r.StartTime.IsZero() {
+		start = r.StartTime.Format("2006-01-02 15:04:05")
+	}
+	answered := "未签到"
+	if r.Answered {
+		answered = "已签到"
+	}
+	return fmt.Sprintf("课程: %s\n类型: %s\n发起人: %s\n开始时间: %s\n状态: %s", r.Course, r.Type, r.Teacher, start, answered)
+}
+
+func formatRollcall(data *APIRollcall) *Rollcall {
+	ret := Rollcall{
+		Id:       data.RollcallId,
+		CourseId: data.CourseId,
+		Course:   data.CourseTitle,
+		Title:    data.Title,
+		Teacher:  data.CreatedByName,
+		Status:   data.RollcallStatus,
+		Answered: data.Status == "on_call" || data.Status == "late",
+	}
+	switch {
+	case data.IsNumber:
+		ret.Type = RollcallTypeNumber
+	case data.IsRadar:
+		ret.Type = RollcallTypeRadar
+	default:
+		ret.Type = RollcallTypeNormal
+	}
+	if t, ok := ParseLNTTime(data.RollcallTime); ok {
+		ret.StartTime = t
+	}
+	return &ret
+}
+
+// GetRollcalls 获取当前用户所有的签到
+func GetRollcalls(client *resty.Client) ([]*Rollcall, error) {
+	res, err := client.R().Get(rollcallUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := utils.UnmarshalJSON[APIRollcalls](res.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*Rollcall
+	for _, rollcall := range data.Rollcalls {
+		ret = append(ret, formatRollcall(rollcall))
+	}
+	return ret, nil
+}
+
+type APIRollcallAnswerError struct {
+	Message   string `json:"message"`
+	ErrorCode string `json:"error_code"`
+}
+
+func checkRollcallAnswer(res *resty.Response) error {
+	if res.IsSuccess() {
+		return nil
+	}
+	data, err := utils.UnmarshalJSON[APIRollcallAnswerError](res.Body())
+	if err == nil && data.Message != "" {
+		return errors.New(data.Message)
+	}
+	return fmt.Errorf("签到请求失败 状态码: %d", res.StatusCode())
+}
+
+// AnswerNumberRollcall 使用数字签到码签到
+func AnswerNumberRollcall(client *resty.Client, rollcallId int, code string) error {
+	res, err := client.R().
+		SetBody(map[string]any{
+			"deviceId":   utils.RandomUUID(),
+			"numberCode": code,
+		}).
+		Put(fmt.Sprintf("https://lnt.xmu.edu.cn/api/rollcall/%d/answer_number_rollcall", rollcallId))
+	if err != nil {
+		return err
+	}
+	return checkRollcallAnswer(res)
+}
+
+// AnswerRadarRollcall 使用定位进行雷达签到
+func AnswerRadarRollcall(client *resty.Client, rollcallId int, latitude, longitude float64) error {
+	res, err := client.R().
+		SetBody(map[string]any{
+			"deviceId":  utils.RandomUUID(),
+			"latitude":  latitude,
+			"longitude": longitude,
+			"accuracy":  30,
+			"altitude":  0,
+			"speed":     nil,
+			"heading":   nil,
+		}).
+		Put(fmt.Sprintf("https://lnt.xmu.edu.cn/api/rollcall/%d/answer?api_version=1.76", rollcallId))
+	if err != nil {
+		return err
+	}
+	return checkRollcallAnswer(res)
+}
+
+// NotifyTarget 代表推送消息的目标，GroupUin为0时私聊推送
+type NotifyTarget struct {
+	Uin      uint32 `json:"uin"`
+	GroupUin uint32 `json:"group_uin"`
+}
+
+func (t *NotifyTarget) ToString() string {
+	if t.GroupUin == 0 {
+		return "私聊"
+	}
+	return fmt.Sprintf("群聊 %d", t.GroupUin)
+}
+
+// RollcallSetting 用户的签到提醒设置
+type RollcallSetting struct {
+	Enabled bool         `json:"enabled"`
+	Target  NotifyTarget `json:"target"`
+}
+
+const rollcallSettingBucket = "rollcall_setting"
+
+func GetRollcallSetting(uin uint32) (*RollcallSetting, bool) {
+	setting, ok, err := DBGet[RollcallSetting](rollcallSettingBucket, uint32ToBytes(uin))
+	if err != nil {
+		Logger.Warning("读取签到提醒设置失败 %v", err)
+		return nil, false
+	}
+	return setting, ok
+}
+
+func SetRollcallSetting(uin uint32, setting *RollcallSetting) error {
+	return DBPut(rollcallSettingBucket, uint32ToBytes(uin), setting)
+}
+
+func RollcallListString(rollcalls []*Rollcall) string {
+	var data []string
+	for i, rollcall := range rollcalls {
+		data = append(data, fmt.Sprintf("%d. %s", i+1, strings.ReplaceAll(rollcall.ToString(), "\n", " ")))
+	}
+	return strings.Join(data, "\n")
+}
@@ -0,0 +1,175 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/vintcessun/XMU-CM-Bot/utils"
+)
+
+// ScoreValue 接口返回的成绩，可能是数字、"缺考" 等字符串或者null
+type ScoreValue string
+
+func (v *ScoreValue) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*v = ""
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*v = ScoreValue(strings.TrimSpace(text))
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*v = ScoreValue(number.String())
+	return nil
+}
+
+type APICourseScoreData struct {
+	Id                int              `json:"id"`
+	Name              string           `json:"name"`
+	StartDate         string           `json:"start_date"`
+	Department        CourseDepartment `json:"department"`
+	Score             ScoreValue       `json:"score"`
+	ScorePassedStatus *bool            `json:"score_passed_status"`
+}
+
+type APICourseScores struct {
+	Courses []*APICourseScoreData `json:"courses"`
+}
+
+type APIActivityScore struct {
+	ActivityId int        `json:"activity_id"`
+	Score      ScoreValue `json:"score"`
+}
+
+type APIActivityScores struct {
+	ActivityScores []*APIActivityScore `json:"activity_scores"`
+}
+
+type ActivityScore struct {
+	Title string
+	Type  string
+	Score string
+}
+
+// CourseScore 课程的成绩和通过情况
+type CourseScore struct {
+	Course     *FormatCourseInside
+	Score      string
+	Passed     *bool
+	Activities []*ActivityScore
+}
+
+func (c *CourseScore) PassedString() string {
+	if c.Passed == nil {
+		return "未知"
+	}
+	if *c.Passed {
+		return "通过"
+	}
+	return "未通过"
+}
+
+func scoreString(score ScoreValue) string {
+	if score == "" {
+		return "-"
+	}
+	return string(score)
+}
+
+// GetCourseScores 获取所有课程的总成绩和通过情况
+func GetCourseScores(client *resty.Client) ([]*CourseScore, error) {
+	res, err := client.R().Get("https://lnt.xmu.edu.cn/api/my-courses?showScorePassedStatus=true")
+	if err != nil {
+		return nil, err
+	}
+	data, err := utils.UnmarshalJSON[APICourseScores](res.Body())
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []*CourseScore
+	for _, course := range data.Courses {
+		formatCourse := FormatCourseInside{Id: course.Id, Name: course.Name, Department: course.Department.Name}
+		if semester, err := GetSemesterStr(course.StartDate); err == nil {
+			formatCourse.Semester = semester
+		}
+		ret = append(ret, &CourseScore{Course: &formatCourse, Score: scoreString(course.Score), Passed: course.ScorePassedStatus})
+	}
+	return ret, nil
+}
+
+// GetActivityScores 获取课程中各个活动的成绩
+func GetActivityScores(courseId int, client *resty.Client) ([]*ActivityScore, error) {
+	res, err := client.R().Get(fmt.Sprintf("https://lnt.xmu.edu.cn/api/courses/%d/activity-scores", courseId))
+	if err != nil {
+		return nil, err
+	}
+	data, err := utils.UnmarshalJSON[APIActivityScores](res.Body())
+	if err != nil {
+		return nil, err
+	}
+	// 没有活动成绩时不再请求活动列表
+	if len(data.ActivityScores) == 0 {
+		return nil, nil
+	}
+
+	activities, err := getAPICourseActivities(courseId, client)
+	if err != nil {
+		return nil, err
+	}
+	activityById := make(map[int]*CourseActivity)
+	for i := range activities.Activities {
+		activityById[activities.Activities[i].Id] = &activities.Activities[i]
+	}
+
+	var ret []*ActivityScore
+	for _, score := range data.ActivityScores {
+		activityScore := ActivityScore{Title: fmt.Sprintf("活动%d", score.ActivityId), Score: scoreString(score.Score)}
+		if activity, ok := activityById[score.ActivityId]; ok {
+			activityScore.Title = activity.Title
+			activityScore.Type = activity.Type
+		}
+		ret = append(ret, &activityScore)
+	}
+	return ret, nil
+}
+
+// FilterCourseScores 筛选成绩，semester为空时不筛选学期，keyword为空时不筛选课程名
+func FilterCourseScores(scores []*CourseScore, semester, keyword string) []*CourseScore {
+	var ret []*CourseScore
+	for _, score := range scores {
+		if semester != "" && score.Course.Semester != semester {
+			continue
+		}
+		if keyword != "" && !strings.Contains(score.Course.Name, keyword) {
+			continue
+		}
+		ret = append(ret, score)
+	}
+	return ret
+}
+
+// CurrentSemester 当前学期的字符串
+func CurrentSemester() string {
+	return GetSemesterInfo(time.Now())
+}
+
+// CourseScoreTableString 将成绩格式化为表格
+func CourseScoreTableString(scores []*CourseScore) string {
+	data := []string{"课程 | 学期 | 成绩 | 通过情况"}
+	for _, score := range scores {
+		data = append(data, fmt.Sprintf("%s | %s | %s | %s", score.Course.Name, score.Course.Semester, score.Score, score.PassedString()))
+		for _, activity := range score.Activities {
+			data = append(data, fmt.Sprintf("  - %s: %s", activity.Title, activity.Score))
+		}
+	}
+	return strings.Join(data, "\n")
+}
@@ -0,0 +1,170 @@
+package tools
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+var fileIndexDelay = 30 * time.Minute
+
+// fileIndexPartialDelay 有课程获取失败时索引不完整，只短暂缓存
+var fileIndexPartialDelay = 2 * time.Minute
+
+var fileIndexWorkerCount = 8
+
+// CourseFile 带有所属课程的文件
+type CourseFile struct {
+	Course *FormatCourseInside
+	File   *FormatFileInside
+}
+
+type FileIndex struct {
+	LastUpdate time.Time
+	Files      []*CourseFile
+	// Partial 表示有课程的文件获取失败
+	Partial bool
+}
+
+func (i *FileIndex) expired() bool {
+	if i.Partial {
+		return time.Since(i.LastUpdate) >= fileIndexPartialDelay
+	}
+	return time.Since(i.LastUpdate) >= fileIndexDelay
+}
+
+type FileIndexCache struct{ m sync.Map }
+
+var FileIndexCacheValue FileIndexCache
+
+func (c *FileIndexCache) get(key string) (*FileIndex, bool) {
+	data, ok := c.m.Load(key)
+	if !ok {
+		return nil, ok
+	} else {
+		switch e := data.(type) {
+		case FileIndex:
+			return &e, ok
+		case *FileIndex:
+			return e, ok
+		default:
+			return nil, false
+		}
+	}
+}
+
+// insert 写入时清理过期的索引，避免退出登录或过期的session一直占用内存
+func (c *FileIndexCache) insert(key string, value *FileIndex) {
+	c.m.Range(func(k, v any) bool {
+		if index, ok := v.(*FileIndex); !ok || index.expired() {
+			c.m.Delete(k)
+		}
+		return true
+	})
+	c.m.Store(key, value)
+}
+
+// GetAllCourseFiles 获取所有课程的文件，结果按session缓存
+func GetAllCourseFiles(session string, client *resty.Client) ([]*CourseFile, error) {
+	index, ok := FileIndexCacheValue.get(session)
+	if ok && !index.expired() {
+		return index.Files, nil
+	}
+
+	courseData, err := GetCourseData(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	var files []*CourseFile
+	partial := false
+	var wg sync.WaitGroup
+	limit := make(chan struct{}, fileIndexWorkerCount)
+	for _, course := range *courseData {
+		wg.Add(1)
+		limit <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-limit }()
+
+			courseFiles, err := GetCourseActivities(course.Id, client)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				Logger.Warning("获取课程文件失败 %s %v", course.Name, err)
+				partial = true
+				return
+			}
+
+			for _, file := range *courseFiles {
+				files = append(files, &CourseFile{Course: course, File: file})
+			}
+		}()
+	}
+	wg.Wait()
+
+	FileIndexCacheValue.insert(session, &FileIndex{LastUpdate: time.Now(), Files: files, Partial: partial})
+
+	return files, nil
+}
+
+type scoredCourseFile struct {
+	file  *CourseFile
+	score int
+}
+
+// SearchCourseFiles 根据关键词搜索文件，所有关键词都需要匹配，按文件名和活动标题的匹配程度排序
+func SearchCourseFiles(files []*CourseFile, keywords []string) []*CourseFile {
+	var scored []scoredCourseFile
+	query := strings.ToLower(strings.Join(keywords, ""))
+	for _, file := range files {
+		fileName := strings.ToLower(file.File.FileName)
+		activity := strings.ToLower(file.File.Activity)
+		course := strings.ToLower(file.Course.Name)
+
+		score := 0
+		matched := true
+		for _, keyword := range keywords {
+			keyword = strings.ToLower(keyword)
+			keywordScore := 0
+			if strings.Contains(fileName, keyword) {
+				keywordScore += 3
+			}
+			if strings.Contains(activity, keyword) {
+				keywordScore += 2
+			}
+			if strings.Contains(course, keyword) {
+				keywordScore += 1
+			}
+			if keywordScore == 0 {
+				matched = false
+				break
+			}
+			score += keywordScore
+		}
+		if !matched {
+			continue
+		}
+		if query != "" && strings.Contains(fileName, query) {
+			score += 2
+		}
+		scored = append(scored, scoredCourseFile{file: file, score: score})
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].file.File.UpdatedAt.After(scored[j].file.File.UpdatedAt)
+	})
+
+	var ret []*CourseFile
+	for _, s := range scored {
+		ret = append(ret, s.file)
+	}
+	return ret
+}
@@ -0,0 +1,117 @@
+package tools
+
+import (
+	"fmt"
+	"time"
+)
+
+// Subscription 群对课程资料的订阅，使用订阅者的session获取课程文件
+type Subscription struct {
+	GroupUin   uint32 `json:"group_uin"`
+	CourseId   int    `json:"course_id"`
+	CourseName string `json:"course_name"`
+	OwnerUin   uint32 `json:"owner_uin"`
+	AutoUpload bool   `json:"auto_upload"`
+}
+
+func (s *Subscription) ToString() string {
+	upload := ""
+	if s.AutoUpload {
+		upload = " 自动上传"
+	}
+	return fmt.Sprintf("%s (%d) 订阅者: %d%s", s.CourseName, s.CourseId, s.OwnerUin, upload)
+}
+
+type SubscriptionFileSnapshot struct {
+	Id        int       `json:"id"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SubscriptionSnapshot 以文件名为键保存课程文件的快照
+type SubscriptionSnapshot map[string]SubscriptionFileSnapshot
+
+const subscriptionBucket = "subscription"
+const subscriptionSnapshotBucket = "subscription_snapshot"
+
+func subscriptionKey(groupUin uint32, courseId int) []byte {
+	return []byte(fmt.Sprintf("%d-%d", groupUin, courseId))
+}
+
+func PutSubscription(subscription *Subscription) error {
+	return DBPut(subscriptionBucket, subscriptionKey(subscription.GroupUin, subscription.CourseId), subscription)
+}
+
+func DeleteSubscription(subscription *Subscription) error {
+	key := subscriptionKey(subscription.GroupUin, subscription.CourseId)
+	err := DBDelete(subscriptionBucket, key)
+	if err != nil {
+		return err
+	}
+	return DBDelete(subscriptionSnapshotBucket, key)
+}
+
+// GetAllSubscriptions 获取所有群的订阅
+func GetAllSubscriptions() ([]*Subscription, error) {
+	var data []*Subscription
+	err := DBForEach(subscriptionBucket, func(key []byte, value *Subscription) error {
+		data = append(data, value)
+		return nil
+	})
+	return data, err
+}
+
+// GetGroupSubscriptions 获取一个群的所有订阅
+func GetGroupSubscriptions(groupUin uint32) ([]*Subscription, error) {
+	subscriptions, err := GetAllSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	var data []*Subscription
+	for _, subscription := range subscriptions {
+		if subscription.GroupUin == groupUin {
+			data = append(data, subscription)
+		}
+	}
+	return data, nil
+}
+
+func GetSubscriptionSnapshot(subscription *Subscription) (SubscriptionSnapshot, bool) {
+	snapshot, ok, err := DBGet[SubscriptionSnapshot](subscriptionSnapshotBucket, subscriptionKey(subscription.GroupUin, subscription.CourseId))
+	if err != nil {
+		Logger.Warning("读取订阅快照失败 %v", err)
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+	return *snapshot, true
+}
+
+func PutSubscriptionSnapshot(subscription *Subscription, snapshot SubscriptionSnapshot) error {
+	return DBPut(subscriptionSnapshotBucket, subscriptionKey(subscription.GroupUin, subscription.CourseId), snapshot)
+}
+
+// NewSubscriptionSnapshot 根据课程文件生成快照
+func NewSubscriptionSnapshot(files FormatFileData) SubscriptionSnapshot {
+	snapshot := make(SubscriptionSnapshot)
+	for _, file := range files {
+		snapshot[file.Name] = SubscriptionFileSnapshot{Id: file.Id, Size: file.Size, UpdatedAt: file.UpdatedAt}
+	}
+	return snapshot
+}
+
+// DiffSubscriptionSnapshot 比较快照和当前的课程文件，返回新增和修改的文件
+func DiffSubscriptionSnapshot(snapshot SubscriptionSnapshot, files FormatFileData) (added, modified FormatFileData) {
+	for _, file := range files {
+		old, ok := snapshot[file.Name]
+		if !ok {
+			added = append(added, file)
+			continue
+		}
+		if old.Id != file.Id || old.Size != file.Size || !old.UpdatedAt.Equal(file.UpdatedAt) {
+			modified = append(modified, file)
+		}
+	}
+	return added, modified
+}
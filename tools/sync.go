@@ -0,0 +1,68 @@
+package tools
+
+// RemoteFile 已经存在于群文件中的文件
+type RemoteFile struct {
+	Id   string
+	Name string
+	Size int64
+}
+
+// FolderSyncPlan 文件夹同步计划
+type FolderSyncPlan struct {
+	// Missing 群文件中不存在的文件
+	Missing []*FormatFileInside
+	// Changed 群文件中存在同名文件但大小不同，Remote为需要替换的旧文件
+	Changed []*FolderSyncChange
+	// Skipped 群文件中已经存在且未变化的文件
+	Skipped []*FormatFileInside
+}
+
+type FolderSyncChange struct {
+	File   *FormatFileInside
+	Remote *RemoteFile
+}
+
+// UploadCount 需要上传的文件数量
+func (p *FolderSyncPlan) UploadCount() int {
+	return len(p.Missing) + len(p.Changed)
+}
+
+// UploadSize 需要上传的文件总大小
+func (p *FolderSyncPlan) UploadSize() int64 {
+	var size int64
+	for _, file := range p.Missing {
+		size += file.Size
+	}
+	for _, change := range p.Changed {
+		size += change.File.Size
+	}
+	return size
+}
+
+// PlanFolderSync 根据文件名和大小比较群文件与课程文件，大小未知时视为未变化
+func PlanFolderSync(remote []*RemoteFile, files FormatFileData) *FolderSyncPlan {
+	remoteByName := make(map[string]*RemoteFile)
+	for _, file := range remote {
+		remoteByName[file.Name] = file
+	}
+
+	var plan FolderSyncPlan
+	seen := make(map[string]bool)
+	for _, file := range files {
+		if seen[file.Name] {
+			continue
+		}
+		seen[file.Name] = true
+
+		existing, ok := remoteByName[file.Name]
+		switch {
+		case !ok:
+			plan.Missing = append(plan.Missing, file)
+		case file.Size != 0 && existing.Size != file.Size:
+			plan.Changed = append(plan.Changed, &FolderSyncChange{File: file, Remote: existing})
+		default:
+			plan.Skipped = append(plan.Skipped, file)
+		}
+	}
+	return &plan
+}
@@ -35,6 +35,26 @@ func Initialize(c *config.Config, logger *utils.ProtocolLogger) error {
 		logger.Error("DB预加载失败")
 	}
 
+	logger.Info("预加载文件缓存")
+	err = FileCacheInit(c)
+	if err != nil {
+		// 下载和上传文件都依赖文件缓存，无法创建缓存目录时不再启动
+		logger.Error("文件缓存预加载失败")
+		return err
+	}
+
+	logger.Info("预加载传输调度")
+	err = TransferInit(c)
+	if err != nil {
+		logger.Error("传输调度预加载失败")
+	}
+
+	logger.Info("预加载文件服务器")
+	err = FileServerInit(c)
+	if err != nil {
+		logger.Error("文件服务器预加载失败 %v", err)
+	}
+
 	return nil
 }
 
@@ -47,6 +67,14 @@ func DeInitialize() {
 		Logger.Error("停止Login任务失败")
 	}
 
+	if FileServer != nil {
+		Logger.Info("停止文件服务器")
+		err = FileServer.Stop()
+		if err != nil {
+			Logger.Error("停止文件服务器失败")
+		}
+	}
+
 	Logger.Info("停止DB任务")
 	err = Db.DeInit()
 	if err != nil {
@@ -0,0 +1,208 @@
+package tools
+
+import (
+	"sync"
+
+	"github.com/vintcessun/XMU-CM-Bot/config"
+)
+
+// TransferTask 一个等待或正在进行的文件传输
+type TransferTask struct {
+	seq     uint64
+	request *TransferRequest
+	f       func()
+	done    chan struct{}
+}
+
+// Wait 等待传输完成
+func (t *TransferTask) Wait() {
+	<-t.done
+}
+
+// TransferRequest 一次指令产生的一组传输，不同请求之间轮流获得传输名额
+type TransferRequest struct {
+	scheduler *TransferScheduler
+	owner     NotifyTarget
+	// waiting 由 scheduler.mu 保护
+	waiting []*TransferTask
+	wg      sync.WaitGroup
+}
+
+// TransferScheduler 全局的文件传输调度器，限制全局、每个用户和每个群同时进行的传输数
+type TransferScheduler struct {
+	mu           sync.Mutex
+	globalLimit  int
+	userLimit    int
+	groupLimit   int
+	running      int
+	userRunning  map[uint32]int
+	groupRunning map[uint32]int
+	// requests 有等待任务的请求，按照轮询顺序排列
+	requests []*TransferRequest
+	next     int
+	seq      uint64
+}
+
+var Transfer *TransferScheduler
+
+func NewTransferScheduler(globalLimit, userLimit, groupLimit int) *TransferScheduler {
+	return &TransferScheduler{
+		globalLimit:  globalLimit,
+		userLimit:    userLimit,
+		groupLimit:   groupLimit,
+		userRunning:  make(map[uint32]int),
+		groupRunning: make(map[uint32]int),
+	}
+}
+
+func TransferInit(c *config.Config) error {
+	Transfer = NewTransferScheduler(c.Download.GetGlobalTransfers(), c.Download.GetUserTransfers(), c.Download.GetGroupTransfers())
+	return nil
+}
+
+// NewRequest 创建一组属于owner的传输，私聊时GroupUin为0，不受群的限制
+func (s *TransferScheduler) NewRequest(owner NotifyTarget) *TransferRequest {
+	return &TransferRequest{scheduler: s, owner: owner}
+}
+
+func (s *TransferScheduler) available(owner NotifyTarget) bool {
+	if owner.Uin != 0 && s.userRunning[owner.Uin] >= s.userLimit {
+		return false
+	}
+	if owner.GroupUin != 0 && s.groupRunning[owner.GroupUin] >= s.groupLimit {
+		return false
+	}
+	return true
+}
+
+// dispatchLocked 在名额允许时轮流从各个请求中取出任务开始传输，调用时需要持有锁
+func (s *TransferScheduler) dispatchLocked() {
+	for s.running < s.globalLimit && len(s.requests) > 0 {
+		started := false
+		for i := range s.requests {
+			index := (s.next + i) % len(s.requests)
+			request := s.requests[index]
+			if !s.available(request.owner) {
+				continue
+			}
+
+			task := request.waiting[0]
+			request.waiting = request.waiting[1:]
+			if len(request.waiting) == 0 {
+				s.requests = append(s.requests[:index], s.requests[index+1:]...)
+				s.next = index
+			} else {
+				s.next = index + 1
+			}
+			if len(s.requests) > 0 {
+				s.next %= len(s.requests)
+			} else {
+				s.next = 0
+			}
+
+			s.start(task)
+			started = true
+			break
+		}
+		if !started {
+			return
+		}
+	}
+}
+
+func (s *TransferScheduler) start(task *TransferTask) {
+	owner := task.request.owner
+	s.running++
+	s.userRunning[owner.Uin]++
+	s.groupRunning[owner.GroupUin]++
+	go func() {
+		defer s.finish(task)
+		task.f()
+	}()
+}
+
+func decreaseCount(m map[uint32]int, key uint32) {
+	m[key]--
+	if m[key] <= 0 {
+		delete(m, key)
+	}
+}
+
+func (s *TransferScheduler) finish(task *TransferTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	owner := task.request.owner
+	s.running--
+	decreaseCount(s.userRunning, owner.Uin)
+	decreaseCount(s.groupRunning, owner.GroupUin)
+	close(task.done)
+	task.request.wg.Done()
+	s.dispatchLocked()
+}
+
+// Status 返回正在传输和排队中的文件数
+func (s *TransferScheduler) Status() (running int, waiting int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, request := range s.requests {
+		waiting += len(request.waiting)
+	}
+	return s.running, waiting
+}
+
+// Submit 提交一个传输任务，有名额时在新的goroutine中执行
+func (r *TransferRequest) Submit(f func()) *TransferTask {
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	task := &TransferTask{seq: s.seq, request: r, f: f, done: make(chan struct{})}
+	if len(r.waiting) == 0 {
+		s.requests = append(s.requests, r)
+	}
+	r.waiting = append(r.waiting, task)
+	r.wg.Add(1)
+	s.dispatchLocked()
+	return task
+}
+
+// Do 提交一个传输任务并等待完成
+func (r *TransferRequest) Do(f func()) {
+	r.Submit(f).Wait()
+}
+
+// Wait 等待所有提交的任务完成
+func (r *TransferRequest) Wait() {
+	r.wg.Wait()
+}
+
+// Ahead 返回在该请求之前排队的其他文件数，没有排队时返回0
+func (r *TransferRequest) Ahead() int {
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(r.waiting) == 0 {
+		return 0
+	}
+	first := r.waiting[0].seq
+	count := 0
+	for _, request := range s.requests {
+		if request == r {
+			continue
+		}
+		for _, task := range request.waiting {
+			if task.seq < first {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Waiting 返回该请求中还在排队的文件数
+func (r *TransferRequest) Waiting() int {
+	r.scheduler.mu.Lock()
+	defer r.scheduler.mu.Unlock()
+	return len(r.waiting)
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Loop 周期运行的后台任务，每次启动时创建新的停止通道，停止后旧的任务不会继续运行
+type Loop struct {
+	mu       sync.Mutex
+	stopChan chan struct{}
+}
+
+// Start 立即运行一次task，之后每隔delay运行一次，已经在运行时返回false
+func (l *Loop) Start(delay time.Duration, task func()) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopChan != nil {
+		return false
+	}
+	stopChan := make(chan struct{})
+	l.stopChan = stopChan
+
+	go func() {
+		for {
+			task()
+			select {
+			case <-stopChan:
+				return
+			case <-time.After(delay):
+			}
+		}
+	}()
+	return true
+}
+
+// Stop 停止任务，正在运行的task结束后不再运行，没有在运行时返回false
+func (l *Loop) Stop() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.stopChan == nil {
+		return false
+	}
+	close(l.stopChan)
+	l.stopChan = nil
+	return true
+}
@@ -1,6 +1,8 @@
 package utils
 
 import (
+	crand "crypto/rand"
+	"fmt"
 	"math/rand"
 	"time"
 )
@@ -13,3 +15,14 @@ func Uniform(min, max float64) float64 {
 	}
 	return min + r.Float64()*(max-min)
 }
+
+// RandomUUID 生成一个随机的UUID v4字符串
+func RandomUUID() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		r.Read(b)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
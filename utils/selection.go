@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseSelection 解析 "1,3,5-8" 格式的序号选择，序号从1开始且不超过max，"all" 或 "全部" 选择所有序号
+func ParseSelection(text string, max int) ([]int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, fmt.Errorf("选择为空")
+	}
+	if strings.EqualFold(text, "all") || text == "全部" {
+		ret := make([]int, max)
+		for i := range ret {
+			ret[i] = i + 1
+		}
+		return ret, nil
+	}
+
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '，' || r == '、' || r == ' '
+	})
+	seen := make(map[int]bool)
+	for _, part := range parts {
+		start, end, isRange := strings.Cut(part, "-")
+		from, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("无法解析序号: %s", part)
+		}
+		to := from
+		if isRange {
+			to, err = strconv.Atoi(end)
+			if err != nil {
+				return nil, fmt.Errorf("无法解析序号: %s", part)
+			}
+		}
+		if from > to {
+			from, to = to, from
+		}
+		if from < 1 || to > max {
+			return nil, fmt.Errorf("序号超出范围: %s，应在 1-%d 之间", part, max)
+		}
+		for i := from; i <= to; i++ {
+			seen[i] = true
+		}
+	}
+
+	var ret []int
+	for i := range seen {
+		ret = append(ret, i)
+	}
+	sort.Ints(ret)
+	return ret, nil
+}
+
+var selectionPattern = regexp.MustCompile(`^([\d\s,，、-]+|(?i:all)|全部)$`)
+
+// IsSelection 判断文本是否像是序号选择
+func IsSelection(text string) bool {
+	return selectionPattern.MatchString(strings.TrimSpace(text))
+}